@@ -0,0 +1,164 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deepthawtz/duncan/config"
+	"github.com/deepthawtz/duncan/deployment"
+	"github.com/deepthawtz/duncan/k8s"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var blueGreen bool
+
+// switchCmd represents the switch command
+var switchCmd = &cobra.Command{
+	Use:   "switch",
+	Short: "Switch traffic to the other blue/green color of an application",
+	Long: `Switch the Services of a blue/green application back to the inactive
+color (e.g., after a bad "duncan deploy --blue-green").
+
+Example:
+
+$ duncan switch --app APP --env ENV
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkAppEnv(app, env)
+		checkAllowedToManage(app, env)
+		connectCluster()
+
+		active, err := k8sClient.ActiveColor(app, env)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if !promptSwitch(active) {
+			return
+		}
+		switched, err := k8sClient.SwitchColor(app, env)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := notifyDeploy(fmt.Sprintf("%s :twisted_rightwards_arrows: *%s %s* switched from %s to %s on %s by %s", emoji(env), app, env, active, switched, cluster, deployUser())); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(switchCmd)
+	switchCmd.Flags().StringVarP(&app, "app", "a", "", "app to switch")
+	switchCmd.Flags().StringVarP(&env, "env", "e", "", "deployment environment (stage, production)")
+	switchCmd.Flags().BoolVarP(&force, "force", "f", false, "bypass prompt before switching")
+	switchCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "cluster to use if several are configured")
+}
+
+// runBlueGreen deploys tag to the inactive color and switches traffic to it
+func runBlueGreen() {
+	var (
+		err      error
+		inactive string
+	)
+	prev, err = k8sClient.BlueGreenTag(app, env, repo, container)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	version := deployVersion()
+	inactive, plan, err = k8sClient.BlueGreenPlan(app, env, version, repo, container)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("blue/green: traffic will switch from %s to %s once %s is available\n\n", k8s.OtherColor(inactive), inactive, inactive)
+	if !promptDeploy() {
+		return
+	}
+
+	diff := "redeployed"
+	if tag != prev {
+		diff = deployment.GithubDiffLink(app, env, prev, tag)
+	}
+	fmt.Println(diff)
+
+	vars := hookVars(app, env, prev, tag, diff)
+	if err := runHooks(k8sClient, config.PreDeploy, vars, version, repo, container); err != nil {
+		fmt.Println(err)
+		if err := notifyDeploy(fmt.Sprintf("%s :x: *%s %s (%s)* blue/green deploy to %s by %s aborted, traffic still routes to %s (%s): %s (diff: %s)", emoji(env), app, env, tag, cluster, deployUser(), k8s.OtherColor(inactive), prev, err, diff)); err != nil {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+
+	if err := k8sClient.DeployBlueGreen(app, env, version, repo, container, rolloutTimeout()); err != nil {
+		fmt.Println(err)
+		if err := notifyDeploy(fmt.Sprintf("%s :x: *%s %s (%s)* blue/green deploy to %s by %s failed, traffic still routes to %s (%s): %s (diff: %s)", emoji(env), app, env, tag, cluster, deployUser(), k8s.OtherColor(inactive), prev, err, diff)); err != nil {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+
+	if err := runHooks(k8sClient, config.PostDeploy, vars, version, repo, container); err != nil {
+		fmt.Println(err)
+		if err := notifyDeploy(fmt.Sprintf("%s :x: *%s %s (%s)* deployed to %s (%s) by %s but failed: %s (diff: %s)", emoji(env), app, env, tag, cluster, inactive, deployUser(), err, diff)); err != nil {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+	recordDeploy(diff)
+	if err := notifyDeploy(fmt.Sprintf("%s :shipit: *%s %s (%s)* deployed to %s (%s) by %s%s (diff: %s)", emoji(env), app, env, tag, cluster, inactive, deployUser(), pinned(), diff)); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func promptSwitch(active string) bool {
+	if force {
+		return true
+	}
+	white := color.New(color.FgWhite, color.Bold).SprintFunc()
+	red := color.New(color.FgRed, color.Bold).SprintFunc()
+	cyan := color.New(color.FgCyan, color.Bold).SprintFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintFunc()
+	yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+	fmt.Printf("You are about to switch traffic:\n\n")
+	fmt.Printf(white("  cluster: %s\n"), white(cluster))
+	fmt.Printf(white("  app: %s\n"), yellow(app))
+	if env == "production" {
+		fmt.Printf(white("  env: %s\n"), red(env))
+	} else {
+		fmt.Printf(white("  env: %s\n"), green(env))
+	}
+	fmt.Printf(white("  color: %s => %s\n"), white(active), cyan(k8s.OtherColor(active)))
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf(white("\nare you sure? (yes/no): "))
+	resp, _ := reader.ReadString('\n')
+
+	resp = strings.TrimSpace(resp)
+	if resp != "yes" {
+		fmt.Println("phew... that was close")
+		return false
+	}
+	return true
+}
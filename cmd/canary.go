@@ -0,0 +1,69 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	canaryPercent int
+	canaryHold    time.Duration
+)
+
+// defaultCanaryHold is used when neither --canary-hold nor canary_hold is set
+const defaultCanaryHold = 5 * time.Minute
+
+// runCanary deploys version as a canary and watches it for the hold
+// period. Unhealthy canaries are torn down before returning an error
+func runCanary(version string) error {
+	fmt.Printf("deploying %d%% canary of %s %s (%s)...\n", canaryPercent, app, env, tag)
+	err := k8sClient.DeployCanary(app, env, version, repo, container, canaryPercent)
+	if err == nil {
+		err = k8sClient.WaitForCanary(app, env, rolloutTimeout(), canaryHoldPeriod())
+	}
+	if err != nil {
+		deleteCanary()
+		return err
+	}
+
+	fmt.Println("canary is healthy, promoting...")
+	return nil
+}
+
+// deleteCanary tears down the canary of a deploy, if any, once it is no
+// longer needed whether or not the deploy succeeded
+func deleteCanary() {
+	if canaryPercent == 0 {
+		return
+	}
+	if err := k8sClient.DeleteCanary(app, env); err != nil {
+		fmt.Printf("WARNING: could not delete canary: %s\n", err)
+	}
+}
+
+// canaryHoldPeriod returns how long a canary is watched before promotion
+func canaryHoldPeriod() time.Duration {
+	if canaryHold > 0 {
+		return canaryHold
+	}
+	if h := viper.GetDuration("canary_hold"); h > 0 {
+		return h
+	}
+	return defaultCanaryHold
+}
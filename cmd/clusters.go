@@ -0,0 +1,315 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deepthawtz/duncan/config"
+	"github.com/deepthawtz/duncan/deployment"
+	"github.com/deepthawtz/duncan/k8s"
+	"github.com/deepthawtz/kit/notify"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/viper"
+)
+
+var (
+	clusterNames     []string
+	parallelClusters bool
+)
+
+// clusterDeploy tracks the progress of a deploy to a single cluster
+type clusterDeploy struct {
+	client *k8s.KubeAPI
+	prev   string
+	diff   string
+	plan   []k8s.ContainerChange
+	status string
+	err    error
+}
+
+// selectClusters returns the clusters of the app/env chosen with --cluster,
+// or every cluster of the app/env if none were chosen
+func selectClusters() []k8s.Cluster {
+	clusters, err := k8s.AppClusters(app, env, clusterNames)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return clusters
+}
+
+// clusterClients connects to every selected cluster
+func clusterClients() []*k8s.KubeAPI {
+	var clients []*k8s.KubeAPI
+	for _, c := range selectClusters() {
+		client, err := k8s.NewClusterClient(c)
+		if err != nil {
+			fmt.Printf("%s: %s\n", c.Name, err)
+			os.Exit(1)
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// appClient connects to the cluster an app/env is deployed to. A single
+// cluster must be configured for the app/env or chosen with --cluster
+func appClient(app, env string) *k8s.KubeAPI {
+	clusters, err := k8s.AppClusters(app, env, clusterNames)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if len(clusters) > 1 {
+		var names []string
+		for _, c := range clusters {
+			names = append(names, c.Name)
+		}
+		fmt.Printf("choose one of %s with --cluster\n", strings.Join(names, ", "))
+		os.Exit(1)
+	}
+	client, err := k8s.NewClusterClient(clusters[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return client
+}
+
+// runClusterDeploys deploys tag to several clusters, one at a time unless
+// --parallel is set. Clusters not yet deployed to are skipped after the
+// first failure
+func runClusterDeploys(clients []*k8s.KubeAPI) {
+	if canaryPercent > 0 || blueGreen {
+		fmt.Println("--canary and --blue-green deploy to a single cluster, choose one with --cluster")
+		os.Exit(1)
+	}
+	checkAllowedToManage(app, env)
+	version := deployVersion()
+
+	var deploys []*clusterDeploy
+	for _, client := range clients {
+		d := &clusterDeploy{client: client, status: "skipped"}
+		var err error
+		d.prev, err = client.CurrentTag(app, env, repo, container)
+		if err != nil {
+			fmt.Printf("%s: %s\n", client.Cluster, err)
+			os.Exit(1)
+		}
+		d.plan, err = client.DeployPlan(app, env, version, repo, container)
+		if err != nil {
+			fmt.Printf("%s: %s\n", client.Cluster, err)
+			os.Exit(1)
+		}
+		d.diff = "redeployed"
+		if tag != d.prev {
+			d.diff = deployment.GithubDiffLink(app, env, d.prev, tag)
+		}
+		deploys = append(deploys, d)
+	}
+	if !promptClusterDeploy(deploys) {
+		return
+	}
+	// hooks run once, e.g. migrations of a shared database, with the
+	// previous tag and diff of the first cluster
+	vars := hookVars(app, env, deploys[0].prev, tag, deploys[0].diff)
+	if err := runHooks(deploys[0].client, config.PreDeploy, vars, version, repo, container); err != nil {
+		fmt.Println(err)
+		if err := notifyDeploy(fmt.Sprintf("%s :x: *%s %s (%s)* deploy by %s aborted before deploying to any cluster: %s", emoji(env), app, env, tag, deployUser(), err)); err != nil {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+
+	failed := false
+	if parallelClusters {
+		var wg sync.WaitGroup
+		for _, d := range deploys {
+			// tell apart the progress of each cluster
+			d.client.Prefix = d.client.Cluster
+			wg.Add(1)
+			go func(d *clusterDeploy) {
+				defer wg.Done()
+				deployToCluster(d, version)
+			}(d)
+		}
+		wg.Wait()
+		for _, d := range deploys {
+			failed = failed || d.err != nil
+		}
+	} else {
+		for _, d := range deploys {
+			if deployToCluster(d, version); d.err != nil {
+				failed = true
+				break
+			}
+		}
+	}
+
+	if !failed {
+		if err := runHooks(deploys[0].client, config.PostDeploy, vars, version, repo, container); err != nil {
+			fmt.Println(err)
+			failed = true
+			for _, d := range deploys {
+				d.status, d.err = "post-deploy hook failed", err
+			}
+		}
+	}
+
+	// only successful deploys are recorded
+	for _, d := range deploys {
+		if d.status != "deployed" {
+			continue
+		}
+		record := &deployment.Record{
+			Previous:  d.prev,
+			Tag:       tag,
+			Digest:    digest,
+			User:      deployUser(),
+			Cluster:   d.client.Cluster,
+			Timestamp: time.Now().UTC(),
+			Diff:      d.diff,
+		}
+		if err := deployment.RecordDeploy(app, env, record); err != nil {
+			fmt.Printf("WARNING: could not record deploy history for %s: %s\n", d.client.Cluster, err)
+		}
+	}
+
+	printClusterDeploys(deploys)
+	if err := notifyClusterDeploys(deploys); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// deployToCluster deploys to a single cluster and waits for its rollout,
+// reverting to the previous tag if the rollout fails and --rollback is set
+func deployToCluster(d *clusterDeploy, version string) {
+	name := d.client.Cluster
+	fmt.Printf("deploying %s %s to %s...\n", app, env, name)
+	d.status = "failed"
+	if d.err = d.client.Deploy(app, env, version, repo, container); d.err != nil {
+		fmt.Printf("%s: %s\n", name, d.err)
+		return
+	}
+	if d.err = d.client.WaitForRollout(app, env, rolloutTimeout()); d.err == nil {
+		d.status = "deployed"
+		return
+	}
+	fmt.Printf("%s: %s\n", name, d.err)
+
+	if !rollback || d.prev == "" || d.prev == tag {
+		return
+	}
+	fmt.Printf("rolling back %s %s on %s to %s...\n", app, env, name, d.prev)
+	err := d.client.Deploy(app, env, d.prev, repo, container)
+	if err == nil {
+		err = d.client.WaitForRollout(app, env, rolloutTimeout())
+	}
+	if err != nil {
+		fmt.Printf("%s: rollback failed: %s\n", name, err)
+		d.status = "rollback FAILED"
+		return
+	}
+	d.status = "rolled back"
+}
+
+// printClusterDeploys displays the outcome of the deploy to each cluster
+func printClusterDeploys(deploys []*clusterDeploy) {
+	var data [][]string
+	for _, d := range deploys {
+		e := ""
+		if d.err != nil {
+			e = d.err.Error()
+		}
+		data = append(data, []string{d.client.Cluster, d.prev, tag, d.status, e})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Cluster", "Previous", "Tag", "Status", "Error"})
+	table.AppendBulk(data)
+	table.Render()
+}
+
+// notifyClusterDeploys sends a single Slack summary of a multi-cluster deploy
+func notifyClusterDeploys(deploys []*clusterDeploy) error {
+	outcome := ":shipit: deployed"
+	for _, d := range deploys {
+		if d.status != "deployed" {
+			outcome = ":x: FAILED"
+		}
+	}
+	msg := fmt.Sprintf("%s *%s %s (%s)* by %s%s %s (diff: %s)\n", emoji(env), app, env, tag, deployUser(), pinned(), outcome, deploys[0].diff)
+	for _, d := range deploys {
+		msg += fmt.Sprintf("%s: %s => %s %s\n", d.client.Cluster, d.prev, tag, d.status)
+	}
+	return notify.Slack(viper.GetString("slack_webhook_url"), fmt.Sprintf("%s %s (%s)", app, env, tag), msg)
+}
+
+func promptClusterDeploy(deploys []*clusterDeploy) bool {
+	if force {
+		return true
+	}
+	white := color.New(color.FgWhite, color.Bold).SprintFunc()
+	red := color.New(color.FgRed, color.Bold).SprintFunc()
+	cyan := color.New(color.FgCyan, color.Bold).SprintFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintFunc()
+	yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+	order := "one at a time"
+	if parallelClusters {
+		order = "in parallel"
+	}
+	fmt.Printf("You are about to deploy to %d clusters %s:\n\n", len(deploys), order)
+	fmt.Printf(white("  app: %s\n"), yellow(app))
+	if env == "production" {
+		fmt.Printf(white("  env: %s\n"), red(env))
+	} else {
+		fmt.Printf(white("  env: %s\n"), green(env))
+	}
+	fmt.Printf(white("  tag: %s\n"), cyan(tag))
+	if digest != "" {
+		fmt.Printf(white("  digest: %s => %s\n"), white(tag), cyan(digest))
+	}
+	if hooks := hookSummary(app, env); hooks != "" {
+		fmt.Printf(white("  hooks (jobs on %s): %s\n"), deploys[0].client.Cluster, cyan(hooks))
+	}
+	for _, d := range deploys {
+		fmt.Printf(white("\n  cluster %s: %s => %s (%s)\n"), yellow(d.client.Cluster), white(d.prev), cyan(tag), k8s.KindSummary(d.plan))
+		fmt.Printf("    diff: %s\n", d.diff)
+		for _, c := range d.plan {
+			fmt.Printf("    %s/%s [%s]: %s => %s\n", c.Kind, c.Name, yellow(c.Container), white(c.From), cyan(c.To))
+		}
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf(white("\nare you sure? (yes/no): "))
+	resp, _ := reader.ReadString('\n')
+
+	resp = strings.TrimSpace(resp)
+	if resp != "yes" {
+		fmt.Println("phew... that was close")
+		return false
+	}
+	return true
+}
@@ -21,11 +21,11 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/deepthawtz/duncan/config"
 	"github.com/deepthawtz/duncan/consul"
 	"github.com/deepthawtz/duncan/vault"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
 var (
@@ -49,7 +49,7 @@ var (
 			pattern := regexp.MustCompile(fmt.Sprintf("(?i).*%s.*", args[0]))
 			green := color.New(color.FgGreen, color.Bold).SprintFunc()
 			matches := map[string]map[string]string{}
-			apps := viper.GetStringSlice("apps")
+			apps := config.Apps()
 			var (
 				wg  sync.WaitGroup
 				mux sync.Mutex
@@ -20,7 +20,9 @@ import (
 	"os"
 	"os/user"
 	"strings"
+	"time"
 
+	"github.com/deepthawtz/duncan/config"
 	"github.com/deepthawtz/duncan/deployment"
 	"github.com/deepthawtz/duncan/docker"
 	"github.com/deepthawtz/duncan/k8s"
@@ -33,10 +35,15 @@ import (
 var (
 	k8sClient *k8s.KubeAPI
 
-	cluster, app, env, tag, repo, prev string
-	force                              bool
+	cluster, app, env, tag, repo, prev, container, digest string
+	force, rollback, pin                                  bool
+	timeout                                               time.Duration
+	plan                                                  []k8s.ContainerChange
 )
 
+// defaultRolloutTimeout is used when neither --timeout nor rollout_timeout is set
+const defaultRolloutTimeout = 5 * time.Minute
+
 // deployCmd represents the deploy command
 var deployCmd = &cobra.Command{
 	Use:   "deploy",
@@ -45,53 +52,90 @@ var deployCmd = &cobra.Command{
 
 Example:
 
-$ duncan deploy --app APP --env ENV --tag TAG [--repo DOCKER_REPO]
+$ duncan deploy --app APP --env ENV --tag TAG [--repo DOCKER_REPO] [--container NAME]
 
 NOTE: tag must exist in docker registry
-`,
 
-	Run: func(cmd *cobra.Command, args []string) {
-		validateDeployFlags()
+Every container (including init containers) running the docker repo is
+updated. Use --container to update a single container by name instead.
 
-		var err error
+Use --pin to deploy the image by the digest the tag currently points to so
+that later pushes to the same tag cannot change what is running.
 
-		cluster = viper.GetString("kubernetes_cluster")
-		k8sClient, err = k8s.NewClient()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		prev, err = k8sClient.CurrentTag(app, env, repo)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
-		if promptDeploy() {
-			if err := k8sClient.Deploy(app, env, tag, repo); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
+Use --canary PERCENT to first run a <name>-canary copy of each Deployment
+with the new tag, sized to receive roughly PERCENT of its traffic. The canary
+is watched for --canary-hold and torn down if any of its pods restart or
+become unready, otherwise the new tag is deployed fully and the canary is
+removed. Canary pods are labeled track=canary so each Deployment must not
+select them, e.g. by selecting track=stable.
 
-			diff := "redeployed"
-			if tag != prev {
-				diff = deployment.GithubDiffLink(repo, prev, tag)
-			}
-			fmt.Println(diff)
+Use --blue-green for apps that cannot run mixed versions. The new tag is
+deployed to the inactive color (blue or green) and once it is fully
+available the app's Services are switched to it. The previous color keeps
+running so traffic can be switched back with "duncan switch".
 
-			username := "bot"
-			u, err := user.Current()
-			if err == nil {
-				username = u.Username
-			}
-			if err := notify.Slack(
-				viper.GetString("slack_webhook_url"),
-				fmt.Sprintf("%s %s (%s)", app, env, tag),
-				fmt.Sprintf("%s :shipit: *%s %s (%s)* deployed to %s by %s (diff: %s)", emoji(env), app, env, tag, cluster, username, diff),
-			); err != nil {
-				fmt.Println(err)
-				os.Exit(1)
-			}
+Several apps can be released together from a manifest file:
+
+$ duncan deploy --manifest release.yml
+
+Every tag is verified before anything is deployed and a single confirmation
+and Slack summary covers the whole release. Apps in a manifest are deployed
+by tag with a regular rollout (--pin, --canary and --blue-green cannot be
+used with --manifest). Example manifest:
+
+  name: spring release
+  parallel: false     # deploy one app at a time (true deploys independent apps together)
+  on_failure: stop    # or rollback to revert every app deployed by the release
+  deploys:
+    - app: api
+      env: production
+      tag: v1.2.3
+    - app: web
+      env: production
+      tag: v4.5.6
+      repo: web-image # (optional) if docker repo/image name differs from app name
+      depends_on: [api]
+
+If several clusters are configured (see kubernetes_clusters in
+example_duncan.yml) the tag is deployed to each of them in turn and no
+further clusters are deployed to after a failure. Use --cluster to choose
+clusters and --parallel to deploy to all of them at once:
+
+$ duncan deploy --app APP --env ENV --tag TAG --cluster kube-us-east,kube-eu-west --parallel
+
+After updating the image duncan waits for every Deployment, StatefulSet and
+DaemonSet to finish rolling out. The wait is limited by --timeout (or
+rollout_timeout in duncan.yml) and the Slack notification reports whether
+it succeeded.
+
+Use --pre-run (or pre_deploy_run of the app in duncan.yml) to run a
+command such as a database migration as a one-off job of the new tag before
+deploying. It runs as the first pre-deploy hook.
+
+Hooks listed under the app's hooks in duncan.yml run before (pre_deploy)
+and after (post_deploy) every deploy. A hook runs a local command, makes
+an HTTP request or runs a command as a one-off job in the cluster and may
+use the variables {{.App}}, {{.Env}}, {{.PreviousTag}}, {{.Tag}} and
+{{.Diff}} (also set as DUNCAN_APP, DUNCAN_ENV, DUNCAN_PREVIOUS_TAG,
+DUNCAN_TAG and DUNCAN_DIFF for commands and jobs). A failing pre-deploy
+hook aborts the deploy and a failing post-deploy hook fails it.
+
+If the rollout does not complete in time every workload is reverted to the
+previously deployed tag (disable with --rollback=false).
+`,
+
+	Run: func(cmd *cobra.Command, args []string) {
+		if manifest != "" {
+			runRelease(manifest)
+			return
+		}
+		validateDeployFlags()
+		if len(selectClusters()) > 1 {
+			runClusterDeploys(clusterClients())
+			return
 		}
+		connectCluster()
+		runDeploy()
 	},
 }
 
@@ -101,7 +145,221 @@ func init() {
 	deployCmd.Flags().StringVarP(&env, "env", "e", "", "deployment environment (stage, production)")
 	deployCmd.Flags().StringVarP(&tag, "tag", "t", "", "tag to deploy")
 	deployCmd.Flags().StringVarP(&repo, "repo", "r", "", "(optional) if docker repo/image name differs from app name")
+	deployCmd.Flags().StringVarP(&container, "container", "c", "", "(optional) only update the container with this name")
+	deployCmd.Flags().IntVar(&canaryPercent, "canary", 0, "percent of traffic to send to a canary of the new tag before deploying it fully")
+	deployCmd.Flags().DurationVar(&canaryHold, "canary-hold", 0, "how long to watch the canary before promoting it (default canary_hold or 5m)")
+	deployCmd.Flags().BoolVar(&blueGreen, "blue-green", false, "deploy to the inactive blue/green color and switch traffic to it")
+	deployCmd.Flags().StringVarP(&manifest, "manifest", "m", "", "deploy every app listed in a release manifest")
+	deployCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "(optional) only deploy to these of the configured clusters")
+	deployCmd.Flags().BoolVar(&parallelClusters, "parallel", false, "deploy to every cluster at once instead of one at a time")
 	deployCmd.Flags().BoolVarP(&force, "force", "f", false, "bypass prompt before deploying")
+	deployCmd.Flags().StringVar(&preRun, "pre-run", "", "command to run as a one-off job of the new tag before deploying (default pre_deploy_run in duncan.yml)")
+	deployCmd.Flags().BoolVar(&pin, "pin", false, "deploy by the image digest the tag points to")
+	deployCmd.Flags().DurationVar(&timeout, "timeout", 0, "how long to wait for rollout to complete (default rollout_timeout or 5m)")
+	deployCmd.Flags().BoolVar(&rollback, "rollback", true, "revert to the previous tag if rollout fails")
+}
+
+// connectCluster sets up the Kubernetes client used for deploys
+func connectCluster() {
+	k8sClient = appClient(app, env)
+	cluster = k8sClient.Cluster
+}
+
+// runDeploy prompts for confirmation, deploys tag, waits for the rollout
+// and notifies Slack of the outcome
+func runDeploy() {
+	var err error
+
+	checkAllowedToManage(app, env)
+	if blueGreen {
+		runBlueGreen()
+		return
+	}
+	prev, err = k8sClient.CurrentTag(app, env, repo, container)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	version := deployVersion()
+	plan, err = k8sClient.DeployPlan(app, env, version, repo, container)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !promptDeploy() {
+		return
+	}
+
+	diff := "redeployed"
+	if tag != prev {
+		diff = deployment.GithubDiffLink(app, env, prev, tag)
+	}
+	fmt.Println(diff)
+
+	vars := hookVars(app, env, prev, tag, diff)
+	if err := runHooks(k8sClient, config.PreDeploy, vars, version, repo, container); err != nil {
+		fmt.Println(err)
+		if err := notifyDeploy(fmt.Sprintf("%s :x: *%s %s (%s)* deploy to %s by %s aborted, %s is still deployed: %s (diff: %s)", emoji(env), app, env, tag, cluster, deployUser(), prev, err, diff)); err != nil {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+
+	if canaryPercent > 0 {
+		if err := runCanary(version); err != nil {
+			fmt.Println(err)
+			if err := notifyDeploy(fmt.Sprintf("%s :x: *%s %s (%s)* canary on %s by %s failed and was torn down, %s is still deployed: %s (diff: %s)", emoji(env), app, env, tag, cluster, deployUser(), prev, err, diff)); err != nil {
+				fmt.Println(err)
+			}
+			os.Exit(1)
+		}
+	}
+
+	if err := k8sClient.Deploy(app, env, version, repo, container); err != nil {
+		fmt.Println(err)
+		deleteCanary()
+		if err := notifyDeploy(fmt.Sprintf("%s :x: *%s %s (%s)* deploy to %s by %s failed: %s (diff: %s)", emoji(env), app, env, tag, cluster, deployUser(), err, diff)); err != nil {
+			fmt.Println(err)
+		}
+		// some workloads may already run the new tag
+		if rollback {
+			rollbackDeploy()
+		}
+		os.Exit(1)
+	}
+	fmt.Printf("updated %s\n", k8s.KindSummary(plan))
+
+	fmt.Println("waiting for rollout to complete...")
+	err = k8sClient.WaitForRollout(app, env, rolloutTimeout())
+	deleteCanary()
+	if err != nil {
+		fmt.Println(err)
+		if err := notifyDeploy(fmt.Sprintf("%s :x: *%s %s (%s)* deploy to %s by %s failed: %s (diff: %s)", emoji(env), app, env, tag, cluster, deployUser(), err, diff)); err != nil {
+			fmt.Println(err)
+		}
+		if rollback {
+			rollbackDeploy()
+		}
+		os.Exit(1)
+	}
+
+	if err := runHooks(k8sClient, config.PostDeploy, vars, version, repo, container); err != nil {
+		fmt.Println(err)
+		if err := notifyDeploy(fmt.Sprintf("%s :x: *%s %s (%s)* deployed to %s by %s but failed: %s (diff: %s)", emoji(env), app, env, tag, cluster, deployUser(), err, diff)); err != nil {
+			fmt.Println(err)
+		}
+		os.Exit(1)
+	}
+	recordDeploy(diff)
+	if err := notifyDeploy(fmt.Sprintf("%s :shipit: *%s %s (%s)* deployed to %s by %s%s (diff: %s)", emoji(env), app, env, tag, cluster, deployUser(), pinned(), diff)); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// deployVersion returns the image version to deploy, pinning tag to
+// its digest if --pin is set
+func deployVersion() string {
+	digest = ""
+	if !pin {
+		return tag
+	}
+	var err error
+	digest, err = docker.TagDigest(repo, tag)
+	if err != nil {
+		fmt.Printf("could not resolve digest of %s:%s: %s\n", docker.ImageName(repo), tag, err)
+		os.Exit(1)
+	}
+	return fmt.Sprintf("%s@%s", tag, digest)
+}
+
+// recordDeploy appends a successful deploy to the Consul deploy ledger
+func recordDeploy(diff string) {
+	record := &deployment.Record{
+		Previous:  prev,
+		Tag:       tag,
+		Digest:    digest,
+		User:      deployUser(),
+		Cluster:   cluster,
+		Timestamp: time.Now().UTC(),
+		Diff:      diff,
+	}
+	if err := deployment.RecordDeploy(app, env, record); err != nil {
+		fmt.Printf("WARNING: could not record deploy history: %s\n", err)
+	}
+}
+
+// rollbackDeploy reverts a failed deploy to the previously deployed tag
+// and waits for the revert to roll out
+func rollbackDeploy() {
+	if prev == "" || prev == tag {
+		fmt.Printf("no previous tag to roll back to for %s %s\n", app, env)
+		return
+	}
+
+	fmt.Printf("rolling back %s %s to %s...\n", app, env, prev)
+	err := k8sClient.Deploy(app, env, prev, repo, container)
+	if err == nil {
+		err = k8sClient.WaitForRollout(app, env, rolloutTimeout())
+	}
+	if err != nil {
+		fmt.Println(err)
+		if err := notifyDeploy(fmt.Sprintf("%s :rotating_light: *%s %s* rollback from %s to %s on %s FAILED, manual intervention required: %s", emoji(env), app, env, tag, prev, cluster, err)); err != nil {
+			fmt.Println(err)
+		}
+		return
+	}
+
+	fmt.Printf("rolled back %s %s to %s\n", app, env, prev)
+	if err := notifyDeploy(fmt.Sprintf("%s :rewind: *%s %s* rolled back from %s to %s on %s", emoji(env), app, env, tag, prev, cluster)); err != nil {
+		fmt.Println(err)
+	}
+}
+
+// rolloutTimeout returns how long to wait for a rollout to complete
+func rolloutTimeout() time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	if t := viper.GetDuration("rollout_timeout"); t > 0 {
+		return t
+	}
+	return defaultRolloutTimeout
+}
+
+// deployUser returns the UNIX user performing the deploy
+func deployUser() string {
+	u, err := user.Current()
+	if err != nil {
+		return "bot"
+	}
+	return u.Username
+}
+
+// pinned describes the digest a deploy is pinned to, if any
+func pinned() string {
+	if digest == "" {
+		return ""
+	}
+	return fmt.Sprintf(" pinned to %s", digest)
+}
+
+// notifyDeploy sends a deploy notification to Slack
+func notifyDeploy(msg string) error {
+	return notify.Slack(
+		viper.GetString("slack_webhook_url"),
+		fmt.Sprintf("%s %s (%s)", app, env, tag),
+		msg,
+	)
+}
+
+// notifyApp sends a notification about app/env (not tied to a deploy) to Slack
+func notifyApp(msg string) error {
+	return notify.Slack(
+		viper.GetString("slack_webhook_url"),
+		fmt.Sprintf("%s %s", app, env),
+		msg,
+	)
 }
 
 func validateDeployFlags() {
@@ -109,13 +367,22 @@ func validateDeployFlags() {
 		fmt.Println("must supply all flags for deploy command")
 		os.Exit(1)
 	}
+	if canaryPercent < 0 || canaryPercent > 99 {
+		fmt.Println("--canary must be a percent between 1 and 99")
+		os.Exit(1)
+	}
+	if canaryPercent > 0 && blueGreen {
+		fmt.Println("--canary and --blue-green cannot be combined")
+		os.Exit(1)
+	}
 
-	// if no --repo flag use app name as repo name
-	// this is important to use
+	// if no --repo flag use the app's repo in duncan.yml or app name
 	if repo == "" {
-		repo = app
+		repo = config.AppEnv(app, env).Repo
 	}
 
+	checkHooks(app, env)
+
 	if err := docker.VerifyTagExists(repo, tag); err != nil {
 		prefix := viper.GetString("docker_repo_prefix")
 		fmt.Printf("could not verify %s/%s:%s exists: %s\n", prefix, repo, tag, err)
@@ -148,7 +415,22 @@ func promptDeploy() bool {
 	} else {
 		fmt.Printf(white("  env: %s\n"), green(env))
 	}
+	if from != "" {
+		fmt.Printf(white("  promoted from: %s\n"), yellow(from))
+	}
 	fmt.Printf(white("  tag: %s => %s\n"), white(prev), cyan(tag))
+	fmt.Printf(white("  diff: %s\n"), deployment.GithubDiffLink(app, env, prev, tag))
+	if digest != "" {
+		fmt.Printf(white("  digest: %s => %s\n"), white(tag), cyan(digest))
+	}
+	fmt.Printf(white("  workloads: %s\n"), cyan(k8s.KindSummary(plan)))
+	if hooks := hookSummary(app, env); hooks != "" {
+		fmt.Printf(white("  hooks: %s\n"), cyan(hooks))
+	}
+	fmt.Printf(white("  containers:\n"))
+	for _, c := range plan {
+		fmt.Printf("    %s/%s [%s]: %s => %s\n", c.Kind, c.Name, yellow(c.Container), white(c.From), cyan(c.To))
+	}
 
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf(white("\nare you sure? (yes/no): "))
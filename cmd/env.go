@@ -59,6 +59,8 @@ var (
 		Run: func(cmd *cobra.Command, args []string) {
 			checkAppEnv(app, env)
 			validateKeyValues(args)
+			checkAllowedToManage(app, env)
+			clients := restartClients()
 
 			u := consul.EnvURL(app, env, true)
 			envVals, err := consul.Read(u)
@@ -86,6 +88,9 @@ var (
 					os.Exit(1)
 				}
 				printSorted(env)
+				if restartPods {
+					restartApp(clients, " to apply env changes")
+				}
 			}
 		},
 	}
@@ -96,6 +101,8 @@ var (
 		Run: func(cmd *cobra.Command, args []string) {
 			checkAppEnv(app, env)
 			validateKeys(args)
+			checkAllowedToManage(app, env)
+			clients := restartClients()
 
 			u := consul.EnvURL(app, env, true)
 			envVals, err := consul.Read(u)
@@ -123,6 +130,9 @@ var (
 					fmt.Println(err)
 					os.Exit(1)
 				}
+				if restartPods {
+					restartApp(clients, " to apply env changes")
+				}
 			}
 		},
 	}
@@ -133,6 +143,8 @@ func init() {
 	envCmd.PersistentFlags().StringVarP(&app, "app", "a", "", "app to manage ENV vars for")
 	envCmd.PersistentFlags().StringVarP(&env, "env", "e", "", "app environment (stage, production)")
 	envSetCmd.Flags().BoolVarP(&force, "force", "f", false, "bypass prompt before setting env")
+	envSetCmd.Flags().BoolVar(&restartPods, "restart", false, "restart the app's pods to apply the changes")
+	envDelCmd.Flags().BoolVar(&restartPods, "restart", false, "restart the app's pods to apply the changes")
 	envCmd.AddCommand(envSetCmd)
 	envCmd.AddCommand(envGetCmd)
 	envCmd.AddCommand(envDelCmd)
@@ -175,6 +187,9 @@ func promptModifyEnvironment(op, cmd, app, env string, changes map[string][2]str
 	for k, transition := range changes {
 		fmt.Printf("change %s from %s => %s\n", k, white(transition[0]), cyan(transition[1]))
 	}
+	if restartPods {
+		fmt.Printf(white("  restart: %s\n"), cyan("yes"))
+	}
 	if op == "set" && cmd == "env" {
 		fmt.Printf("\n%s ", red("WARNING:"))
 		fmt.Printf(white("environment variables set w/ env command are NOT encrypted\n"))
@@ -0,0 +1,114 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deepthawtz/duncan/config"
+	"github.com/deepthawtz/duncan/k8s"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// defaultShell runs bash if the image has it, sh otherwise
+var defaultShell = []string{"/bin/sh", "-c", "command -v bash >/dev/null && exec bash || exec sh"}
+
+var pod string
+
+// execCmd represents the exec command
+var execCmd = &cobra.Command{
+	Use:   "exec [-- COMMAND]",
+	Short: "Run a command or console in a pod of an application",
+	Long: `Run a command in a ready pod of an application, by default an
+interactive shell.
+
+Example:
+
+$ duncan exec --app APP --env ENV -- bundle exec rails console
+
+A terminal is allocated when run from one. The same permissions as deploy
+are required and production sessions are announced in Slack.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkAppEnv(app, env)
+		checkAllowedToManage(app, env)
+		client := appClient(app, env)
+
+		p, err := client.ExecPod(app, env, pod)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if container == "" {
+			container = k8s.ExecContainer(p, config.AppEnv(app, env).Repo)
+		}
+		command := args
+		if len(command) == 0 {
+			command = defaultShell
+		}
+
+		if env == "production" {
+			msg := fmt.Sprintf(":computer: %s opened a session on %s/%s: `%s`", deployUser(), p.Name, container, strings.Join(command, " "))
+			if err := notifyApp(msg); err != nil {
+				fmt.Println(err)
+			}
+		}
+
+		opts := k8s.ExecOptions{
+			Pod:       p.Name,
+			Container: container,
+			Command:   command,
+			Stdin:     os.Stdin,
+			Stdout:    os.Stdout,
+			Stderr:    os.Stderr,
+		}
+		fd := int(os.Stdin.Fd())
+		if terminal.IsTerminal(fd) {
+			width, height, err := terminal.GetSize(fd)
+			if err == nil {
+				opts.Width, opts.Height = uint16(width), uint16(height)
+			}
+			state, err := terminal.MakeRaw(fd)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			opts.TTY = true
+			err = client.Exec(opts)
+			terminal.Restore(fd, state)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
+		}
+		if err := client.Exec(opts); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(execCmd)
+	execCmd.Flags().StringVarP(&app, "app", "a", "", "app to run command in")
+	execCmd.Flags().StringVarP(&env, "env", "e", "", "app environment (stage, production)")
+	execCmd.Flags().StringVarP(&pod, "pod", "p", "", "(optional) pod to run command in (default any ready pod)")
+	execCmd.Flags().StringVarP(&container, "container", "c", "", "(optional) container to run command in (default the container running the app's docker repo)")
+	execCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "cluster to run command in if the app runs in several")
+}
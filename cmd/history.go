@@ -0,0 +1,68 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/deepthawtz/duncan/deployment"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show deploy history for an application",
+	Run: func(cmd *cobra.Command, args []string) {
+		checkAppEnv(app, env)
+
+		records, err := deployment.History(app, env)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		green := color.New(color.FgGreen, color.Bold).SprintFunc()
+		cyan := color.New(color.FgCyan, color.Bold).SprintFunc()
+		white := color.New(color.FgWhite, color.Bold).SprintFunc()
+		yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+		var data [][]string
+		for _, r := range records {
+			data = append(data, []string{
+				cyan(r.Timestamp.Local().Format("2006-01-02 15:04:05")),
+				white(r.Previous),
+				white(r.Tag),
+				yellow(r.User),
+				cyan(r.Cluster),
+				r.Diff,
+			})
+		}
+
+		fmt.Println(green(fmt.Sprintf("%s-%s", app, env)))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Time", "Previous", "Tag", "User", "Cluster", "Diff"})
+		table.AppendBulk(data)
+		table.Render()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().StringVarP(&app, "app", "a", "", "app to show deploy history for")
+	historyCmd.Flags().StringVarP(&env, "env", "e", "", "deployment environment (stage, production)")
+}
@@ -0,0 +1,108 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/deepthawtz/duncan/config"
+	"github.com/deepthawtz/duncan/deployment"
+	"github.com/deepthawtz/duncan/k8s"
+)
+
+// skipHooks disables running hooks, e.g. when rolling back to a tag whose
+// migrations already ran
+var skipHooks bool
+
+// hookVars returns the variables of the hooks of deploying tag to app/env
+func hookVars(app, env, prev, tag, diff string) deployment.HookVars {
+	return deployment.HookVars{App: app, Env: env, PreviousTag: prev, Tag: tag, Diff: diff}
+}
+
+// hookConfig returns the configuration of app/env whose hooks run when
+// deploying it, --pre-run replacing pre_deploy_run
+func hookConfig(app, env string) *config.App {
+	a := config.AppEnv(app, env)
+	if preRun != "" {
+		a.PreDeployRun = preRun
+	}
+	return a
+}
+
+// checkHooks exits if the hooks of app/env in duncan.yml are invalid
+func checkHooks(app, env string) {
+	if err := hookConfig(app, env).CheckHooks(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+// hookSummary lists the hooks of app/env for deploy prompts
+// e.g., pre-deploy: job `rake db:migrate`, post-deploy: smoke test
+func hookSummary(app, env string) string {
+	a := hookConfig(app, env)
+	var summary []string
+	for _, stage := range config.Stages {
+		hooks := a.StageHooks(stage)
+		if len(hooks) == 0 {
+			continue
+		}
+		var names []string
+		for _, h := range hooks {
+			names = append(names, h.String())
+		}
+		summary = append(summary, fmt.Sprintf("%s: %s", stageName(stage), strings.Join(names, ", ")))
+	}
+	if skipHooks && len(summary) > 0 {
+		return "SKIPPED " + strings.Join(summary, "; ")
+	}
+	return strings.Join(summary, "; ")
+}
+
+// runHooks runs the hooks of a stage of an app/env in order, stopping at
+// the first failure. Job hooks run in the cluster of client as Jobs of the
+// version being deployed
+func runHooks(client *k8s.KubeAPI, stage string, vars deployment.HookVars, version, repo, container string) error {
+	if skipHooks {
+		fmt.Printf("skipping %s hooks of %s %s\n", stageName(stage), vars.App, vars.Env)
+		return nil
+	}
+	for _, h := range hookConfig(vars.App, vars.Env).StageHooks(stage) {
+		fmt.Printf("running %s hook %s of %s %s on %s...\n", stageName(stage), h, vars.App, vars.Env, client.Cluster)
+		runJob := func(command string, timeout time.Duration) error {
+			return client.RunJob(vars.App, vars.Env, k8s.RunOptions{
+				Command:   []string{"/bin/sh", "-c", command},
+				Container: container,
+				Tag:       version,
+				Repo:      repo,
+				Env:       vars.Environment(),
+				Timeout:   timeout,
+				Out:       os.Stdout,
+			})
+		}
+		if err := deployment.RunHook(h, vars, os.Stdout, runJob); err != nil {
+			return fmt.Errorf("%s hook %s", stageName(stage), err)
+		}
+	}
+	return nil
+}
+
+// stageName returns the name of a hook stage as shown to users
+func stageName(stage string) string {
+	return strings.Replace(stage, "_", "-", -1)
+}
@@ -22,17 +22,29 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var wide bool
+
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List applications",
+	Long: `List the workloads of applications and the tags they are running
+along with ready/desired replicas, container restarts, time since the last
+deploy, CPU and memory requests/limits and whether a rollout is in progress.
+
+If several clusters are configured the tags running in each cluster are
+shown side by side.
+`,
 	Run: func(cmd *cobra.Command, args []string) {
-		k8sClient, err := k8s.NewClient()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+		clients := clusterClients()
+		if len(clients) > 1 {
+			if err := k8s.ListClusters(clients, app, env); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			return
 		}
-		if err := k8sClient.List(app, env); err != nil {
+		if err := clients[0].List(app, env, wide); err != nil {
 			fmt.Println(err)
 			os.Exit(1)
 		}
@@ -44,4 +56,6 @@ func init() {
 
 	listCmd.Flags().StringVarP(&app, "app", "a", "", "optionally filter by app")
 	listCmd.Flags().StringVarP(&env, "env", "e", "", "optionally filter by environment (stage, production)")
+	listCmd.Flags().BoolVarP(&wide, "wide", "w", false, "show workload names, images, updated replicas and rollout details")
+	listCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "(optional) only list these of the configured clusters")
 }
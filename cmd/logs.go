@@ -0,0 +1,63 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/deepthawtz/duncan/k8s"
+	"github.com/spf13/cobra"
+)
+
+var (
+	follow bool
+	since  time.Duration
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Stream the logs of an application",
+	Long: `Stream the logs of every pod of an application at the same time.
+Each line is prefixed with the name of the pod it came from.
+
+Example:
+
+$ duncan logs --app APP --env ENV --follow --since 10m
+
+Use --container to only show the logs of one container of the app's pods.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkAppEnv(app, env)
+		client := appClient(app, env)
+		opts := k8s.LogOptions{Follow: follow, Since: since, Container: container}
+		if err := client.Logs(app, env, opts, os.Stdout); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().StringVarP(&app, "app", "a", "", "app to show logs of")
+	logsCmd.Flags().StringVarP(&env, "env", "e", "", "app environment (stage, production)")
+	logsCmd.Flags().StringVarP(&container, "container", "c", "", "(optional) only show logs of this container")
+	logsCmd.Flags().BoolVarP(&follow, "follow", "f", false, "keep streaming new logs")
+	logsCmd.Flags().DurationVar(&since, "since", 0, "(optional) only show logs newer than a duration, e.g. 10m")
+	logsCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "cluster to show logs from if the app runs in several")
+}
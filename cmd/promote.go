@@ -0,0 +1,139 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deepthawtz/duncan/config"
+	"github.com/deepthawtz/duncan/consul"
+	"github.com/deepthawtz/duncan/docker"
+	"github.com/deepthawtz/duncan/vault"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	from        string
+	checkConfig bool
+)
+
+// promoteCmd represents the promote command
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Deploy the tag running in one environment to another",
+	Long: `Deploy the tag currently running in one environment to another.
+
+Example:
+
+$ duncan promote --app APP --from stage --to production [--repo DOCKER_REPO] [--check-config]
+
+With --check-config the ENV (Consul) and secret (Vault) keys of both
+environments are compared first and promotion is aborted if the target
+environment is missing any keys set in the source environment.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if app == "" || from == "" || env == "" {
+			fmt.Println("must provide --app, --from and --to flags")
+			os.Exit(1)
+		}
+		if from == env {
+			fmt.Println("--from and --to must be different environments")
+			os.Exit(1)
+		}
+		if repo == "" {
+			repo = config.AppEnv(app, env).Repo
+		}
+		if checkConfig {
+			checkMissingConfig()
+		}
+		checkHooks(app, env)
+
+		// the source env may run in another cluster or namespace
+		var err error
+		tag, err = appClient(app, from).CurrentTag(app, from, repo, container)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := docker.VerifyTagExists(repo, tag); err != nil {
+			prefix := viper.GetString("docker_repo_prefix")
+			fmt.Printf("could not verify %s/%s:%s exists: %s\n", prefix, repo, tag, err)
+			os.Exit(1)
+		}
+		connectCluster()
+		runDeploy()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(promoteCmd)
+	promoteCmd.Flags().StringVarP(&app, "app", "a", "", "app to promote")
+	promoteCmd.Flags().StringVar(&from, "from", "", "environment to promote from (e.g., stage)")
+	promoteCmd.Flags().StringVar(&env, "to", "", "environment to promote to (e.g., production)")
+	promoteCmd.Flags().StringVarP(&repo, "repo", "r", "", "(optional) if docker repo/image name differs from app name")
+	promoteCmd.Flags().StringVarP(&container, "container", "c", "", "(optional) only update the container with this name")
+	promoteCmd.Flags().BoolVar(&checkConfig, "check-config", false, "abort if ENV or secret keys are missing in the target environment")
+	promoteCmd.Flags().BoolVarP(&force, "force", "f", false, "bypass prompt before deploying")
+	promoteCmd.Flags().BoolVar(&pin, "pin", false, "deploy by the image digest the tag points to")
+	promoteCmd.Flags().DurationVar(&timeout, "timeout", 0, "how long to wait for rollout to complete (default rollout_timeout or 5m)")
+	promoteCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "cluster to use if several are configured")
+}
+
+// checkMissingConfig exits if the target env is missing ENV or secret
+// keys that are set in the source env
+func checkMissingConfig() {
+	missing := false
+	report := func(typ string, keys []string) {
+		if len(keys) > 0 {
+			missing = true
+			fmt.Printf("%s keys set in %s but missing in %s: %s\n", typ, from, env, strings.Join(keys, ", "))
+		}
+	}
+
+	fromEnv, err := consul.Read(consul.EnvURL(app, from, true))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// a missing keyspace in the target env means every key is missing
+	toEnv, err := consul.Read(consul.EnvURL(app, env, true))
+	if _, notFound := err.(*consul.NotFoundError); err != nil && !notFound {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	report("env", config.MissingKeys(fromEnv, toEnv))
+
+	fromSecrets, err := vault.Read(vault.SecretsURL(app, from))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	toSecrets, err := vault.Read(vault.SecretsURL(app, env))
+	if _, notFound := err.(*vault.NotFoundError); notFound {
+		toSecrets = &vault.Secrets{}
+	} else if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	report("secrets", config.MissingKeys(fromSecrets.KVPairs, toSecrets.KVPairs))
+
+	if missing {
+		fmt.Println("aborting promotion: set the missing keys with `duncan env set` and `duncan secrets set` first")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/deepthawtz/duncan/config"
+	"github.com/deepthawtz/duncan/deployment"
+	"github.com/deepthawtz/duncan/docker"
+	"github.com/deepthawtz/duncan/k8s"
+	"github.com/deepthawtz/kit/notify"
+	"github.com/fatih/color"
+	"github.com/spf13/viper"
+)
+
+var manifest string
+
+// release tracks the progress of a single manifest entry
+type release struct {
+	*deployment.ManifestEntry
+	client *k8s.KubeAPI
+	prev   string
+	diff   string
+	plan   []k8s.ContainerChange
+	status string
+	err    error
+}
+
+// runRelease deploys every app listed in a release manifest
+func runRelease(path string) {
+	if pin || canaryPercent > 0 || blueGreen {
+		fmt.Println("--pin, --canary and --blue-green cannot be used with --manifest")
+		os.Exit(1)
+	}
+
+	m, err := deployment.LoadManifest(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	verified := true
+	for _, e := range m.Deploys {
+		if err := docker.VerifyTagExists(e.Repo, e.Tag); err != nil {
+			fmt.Printf("could not verify %s:%s exists: %s\n", docker.ImageName(e.Repo), e.Tag, err)
+			verified = false
+		}
+	}
+	if !verified {
+		os.Exit(1)
+	}
+
+	releases := map[*deployment.ManifestEntry]*release{}
+	for _, e := range m.Deploys {
+		checkAllowedToManage(e.App, e.Env)
+		checkHooks(e.App, e.Env)
+		r := &release{ManifestEntry: e, client: appClient(e.App, e.Env), status: "skipped"}
+		r.prev, err = r.client.CurrentTag(e.App, e.Env, e.Repo, e.Container)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		r.plan, err = r.client.DeployPlan(e.App, e.Env, e.Tag, e.Repo, e.Container)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		r.diff = "redeployed"
+		if r.Tag != r.prev {
+			r.diff = deployment.GithubDiffLink(r.App, r.Env, r.prev, r.Tag)
+		}
+		releases[e] = r
+	}
+
+	waves, err := releaseWaves(m)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !promptRelease(m, waves, releases) {
+		return
+	}
+
+	failed := false
+	for _, wave := range waves {
+		var wg sync.WaitGroup
+		for _, e := range wave {
+			wg.Add(1)
+			go func(r *release) {
+				defer wg.Done()
+				deployRelease(r)
+			}(releases[e])
+		}
+		wg.Wait()
+
+		for _, e := range wave {
+			failed = failed || releases[e].err != nil
+		}
+		if failed {
+			break
+		}
+	}
+
+	if failed && m.OnFailure == "rollback" {
+		for _, e := range m.Deploys {
+			if r := releases[e]; r.status != "skipped" {
+				rollbackRelease(r)
+			}
+		}
+	}
+
+	for _, e := range m.Deploys {
+		r := releases[e]
+		if r.status != "deployed" {
+			continue
+		}
+		record := &deployment.Record{
+			Previous:  r.prev,
+			Tag:       r.Tag,
+			User:      deployUser(),
+			Cluster:   r.client.Cluster,
+			Timestamp: time.Now().UTC(),
+			Diff:      r.diff,
+		}
+		if err := deployment.RecordDeploy(r.App, r.Env, record); err != nil {
+			fmt.Printf("WARNING: could not record deploy history for %s: %s\n", r.Name(), err)
+		}
+	}
+
+	if err := notifyRelease(m, releases); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// releaseWaves returns the groups of entries to deploy together. Sequential
+// releases deploy one entry at a time in dependency order
+func releaseWaves(m *deployment.Manifest) ([][]*deployment.ManifestEntry, error) {
+	if m.Parallel {
+		return m.Waves()
+	}
+	order, err := m.Order()
+	if err != nil {
+		return nil, err
+	}
+	var waves [][]*deployment.ManifestEntry
+	for _, e := range order {
+		waves = append(waves, []*deployment.ManifestEntry{e})
+	}
+	return waves, nil
+}
+
+// deployRelease deploys a single manifest entry and waits for its rollout
+func deployRelease(r *release) {
+	fmt.Printf("deploying %s %s...\n", r.Name(), r.Tag)
+	r.status = "failed"
+	vars := hookVars(r.App, r.Env, r.prev, r.Tag, r.diff)
+	if r.err = runHooks(r.client, config.PreDeploy, vars, r.Tag, r.Repo, r.Container); r.err != nil {
+		fmt.Printf("%s: %s\n", r.Name(), r.err)
+		return
+	}
+	if r.err = r.client.Deploy(r.App, r.Env, r.Tag, r.Repo, r.Container); r.err != nil {
+		fmt.Printf("%s: %s\n", r.Name(), r.err)
+		return
+	}
+	if r.err = r.client.WaitForRollout(r.App, r.Env, rolloutTimeout()); r.err != nil {
+		fmt.Printf("%s: %s\n", r.Name(), r.err)
+		return
+	}
+	if r.err = runHooks(r.client, config.PostDeploy, vars, r.Tag, r.Repo, r.Container); r.err != nil {
+		fmt.Printf("%s: %s\n", r.Name(), r.err)
+		return
+	}
+	r.status = "deployed"
+}
+
+// rollbackRelease reverts a manifest entry to its previous tag
+func rollbackRelease(r *release) {
+	if r.prev == r.Tag {
+		return
+	}
+	fmt.Printf("rolling back %s to %s...\n", r.Name(), r.prev)
+	err := r.client.Deploy(r.App, r.Env, r.prev, r.Repo, r.Container)
+	if err == nil {
+		err = r.client.WaitForRollout(r.App, r.Env, rolloutTimeout())
+	}
+	if err != nil {
+		fmt.Printf("%s: rollback failed: %s\n", r.Name(), err)
+		r.status = "rollback FAILED"
+		return
+	}
+	r.status = "rolled back"
+}
+
+// notifyRelease sends a single Slack summary of a release
+func notifyRelease(m *deployment.Manifest, releases map[*deployment.ManifestEntry]*release) error {
+	name := m.Name
+	if name == "" {
+		name = manifest
+	}
+	outcome := ":shipit: deployed"
+	for _, r := range releases {
+		if r.status != "deployed" {
+			outcome = ":x: FAILED"
+		}
+	}
+	msg := fmt.Sprintf("*release %s* by %s %s\n", name, deployUser(), outcome)
+	for _, e := range m.Deploys {
+		r := releases[e]
+		msg += fmt.Sprintf("%s *%s %s (%s)* %s on %s (diff: %s)\n", emoji(r.Env), r.App, r.Env, r.Tag, r.status, r.client.Cluster, r.diff)
+	}
+	return notify.Slack(viper.GetString("slack_webhook_url"), fmt.Sprintf("release %s", name), msg)
+}
+
+func promptRelease(m *deployment.Manifest, waves [][]*deployment.ManifestEntry, releases map[*deployment.ManifestEntry]*release) bool {
+	if force {
+		return true
+	}
+	white := color.New(color.FgWhite, color.Bold).SprintFunc()
+	red := color.New(color.FgRed, color.Bold).SprintFunc()
+	cyan := color.New(color.FgCyan, color.Bold).SprintFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintFunc()
+	yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+	fmt.Printf("You are about to deploy release %s:\n\n", yellow(m.Name))
+	fmt.Printf(white("  on failure: %s\n"), cyan(m.OnFailure))
+	for i, wave := range waves {
+		fmt.Printf(white("\n  step %d:\n"), i+1)
+		for _, e := range wave {
+			r := releases[e]
+			envColor := green
+			if r.Env == "production" {
+				envColor = red
+			}
+			fmt.Printf("    %s %s on %s: %s => %s (diff: %s)\n", yellow(r.App), envColor(r.Env), white(r.client.Cluster), white(r.prev), cyan(r.Tag), r.diff)
+			if hooks := hookSummary(r.App, r.Env); hooks != "" {
+				fmt.Printf("      hooks: %s\n", cyan(hooks))
+			}
+			for _, c := range r.plan {
+				fmt.Printf("      %s/%s [%s]: %s => %s\n", c.Kind, c.Name, yellow(c.Container), white(c.From), cyan(c.To))
+			}
+		}
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf(white("\nare you sure? (yes/no): "))
+	resp, _ := reader.ReadString('\n')
+
+	resp = strings.TrimSpace(resp)
+	if resp != "yes" {
+		fmt.Println("phew... that was close")
+		return false
+	}
+	return true
+}
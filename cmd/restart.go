@@ -0,0 +1,130 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deepthawtz/duncan/k8s"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var restartPods bool
+
+// restartCmd represents the restart command
+var restartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "Restart the pods of an application",
+	Long: `Restart every pod of an application one at a time, e.g. so that
+changed ENV vars or secrets are picked up without a deploy.
+
+Example:
+
+$ duncan restart --app APP --env ENV
+
+Every Deployment, StatefulSet and DaemonSet of the app is rolled the same
+way as "kubectl rollout restart" and duncan waits for the rollout to
+complete. env and secrets set/del accept --restart to restart right after
+changing the app's configuration.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkAppEnv(app, env)
+		checkAllowedToManage(app, env)
+		clients := clusterClients()
+		if !promptRestart(clients) {
+			return
+		}
+		restartApp(clients, "")
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(restartCmd)
+	restartCmd.Flags().StringVarP(&app, "app", "a", "", "app to restart")
+	restartCmd.Flags().StringVarP(&env, "env", "e", "", "app environment (stage, production)")
+	restartCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "(optional) only restart in these of the configured clusters")
+	restartCmd.Flags().BoolVarP(&force, "force", "f", false, "bypass prompt before restarting")
+	restartCmd.Flags().DurationVar(&timeout, "timeout", 0, "how long to wait for rollout to complete (default rollout_timeout or 5m)")
+}
+
+// restartClients connects to the clusters of app/env if --restart is set
+// so that connection problems are found before configuration is changed
+func restartClients() []*k8s.KubeAPI {
+	if !restartPods {
+		return nil
+	}
+	return clusterClients()
+}
+
+// restartApp restarts the pods of app/env in each cluster and waits for
+// the rollout. reason (e.g., " to apply env changes") is added to Slack
+// notifications
+func restartApp(clients []*k8s.KubeAPI, reason string) {
+	for _, client := range clients {
+		fmt.Printf("restarting %s %s on %s...\n", app, env, client.Cluster)
+		err := client.Restart(app, env)
+		if err == nil {
+			fmt.Println("waiting for rollout to complete...")
+			err = client.WaitForRollout(app, env, rolloutTimeout())
+		}
+		if err != nil {
+			fmt.Println(err)
+			if err := notifyApp(fmt.Sprintf("%s :x: *%s %s* restart%s on %s by %s failed: %s", emoji(env), app, env, reason, client.Cluster, deployUser(), err)); err != nil {
+				fmt.Println(err)
+			}
+			os.Exit(1)
+		}
+		if err := notifyApp(fmt.Sprintf("%s :recycle: *%s %s* restarted%s on %s by %s", emoji(env), app, env, reason, client.Cluster, deployUser())); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func promptRestart(clients []*k8s.KubeAPI) bool {
+	if force {
+		return true
+	}
+	white := color.New(color.FgWhite, color.Bold).SprintFunc()
+	red := color.New(color.FgRed, color.Bold).SprintFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintFunc()
+	yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+	var names []string
+	for _, c := range clients {
+		names = append(names, c.Cluster)
+	}
+	fmt.Printf("You are about to restart:\n\n")
+	fmt.Printf(white("  cluster: %s\n"), white(strings.Join(names, ", ")))
+	fmt.Printf(white("  app: %s\n"), yellow(app))
+	if env == "production" {
+		fmt.Printf(white("  env: %s\n"), red(env))
+	} else {
+		fmt.Printf(white("  env: %s\n"), green(env))
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf(white("\nare you sure? (yes/no): "))
+	resp, _ := reader.ReadString('\n')
+
+	resp = strings.TrimSpace(resp)
+	if resp != "yes" {
+		fmt.Println("phew... that was close")
+		return false
+	}
+	return true
+}
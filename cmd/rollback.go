@@ -0,0 +1,141 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/deepthawtz/duncan/config"
+	"github.com/deepthawtz/duncan/deployment"
+	"github.com/deepthawtz/duncan/docker"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	to        string
+	steps     int
+	withHooks bool
+)
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Redeploy a previously deployed tag",
+	Long: `Redeploy a previously deployed tag of an application.
+
+By default the tag deployed before the current one is redeployed. Use --steps
+to go further back in deploy history or --to to pick an explicit tag.
+
+Deploy history is read from the Consul deploy ledger (see: duncan history).
+If the ledger is unavailable or out of date, Kubernetes ReplicaSet revisions
+are used instead.
+
+Pre-deploy and post-deploy hooks (including pre_deploy_run, e.g.
+migrations) are not run when rolling back unless --hooks is given.
+
+Example:
+
+$ duncan rollback --app APP --env ENV [--steps N | --to TAG] [--repo DOCKER_REPO]
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		validateRollbackFlags(cmd)
+		skipHooks = !withHooks
+		if withHooks {
+			checkHooks(app, env)
+		}
+		connectCluster()
+
+		if to != "" {
+			tag = to
+		} else {
+			history, err := deployHistory()
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			if steps >= len(history) {
+				fmt.Printf("cannot roll back %d step(s): only %d previous tag(s) recorded for %s %s\n", steps, len(history)-1, app, env)
+				os.Exit(1)
+			}
+			tag = history[steps]
+		}
+
+		if err := docker.VerifyTagExists(repo, tag); err != nil {
+			prefix := viper.GetString("docker_repo_prefix")
+			fmt.Printf("could not verify %s/%s:%s exists: %s\n", prefix, repo, tag, err)
+			os.Exit(1)
+		}
+		runDeploy()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.Flags().StringVarP(&app, "app", "a", "", "app to roll back")
+	rollbackCmd.Flags().StringVarP(&env, "env", "e", "", "deployment environment (stage, production)")
+	rollbackCmd.Flags().StringVarP(&repo, "repo", "r", "", "(optional) if docker repo/image name differs from app name")
+	rollbackCmd.Flags().StringVarP(&container, "container", "c", "", "(optional) only update the container with this name")
+	rollbackCmd.Flags().StringVar(&to, "to", "", "tag to roll back to")
+	rollbackCmd.Flags().IntVar(&steps, "steps", 1, "number of deploys to go back")
+	rollbackCmd.Flags().BoolVarP(&force, "force", "f", false, "bypass prompt before deploying")
+	rollbackCmd.Flags().BoolVar(&pin, "pin", false, "deploy by the image digest the tag points to")
+	rollbackCmd.Flags().DurationVar(&timeout, "timeout", 0, "how long to wait for rollout to complete (default rollout_timeout or 5m)")
+	rollbackCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "cluster to use if several are configured")
+	rollbackCmd.Flags().BoolVar(&withHooks, "hooks", false, "run the app's pre-deploy and post-deploy hooks (e.g. migrations) of the tag rolled back to")
+}
+
+func validateRollbackFlags(cmd *cobra.Command) {
+	checkAppEnv(app, env)
+	if to != "" && cmd.Flags().Changed("steps") {
+		fmt.Println("must supply only one of --to or --steps")
+		os.Exit(1)
+	}
+	if steps < 1 {
+		fmt.Println("--steps must be 1 or greater")
+		os.Exit(1)
+	}
+	if repo == "" {
+		repo = config.AppEnv(app, env).Repo
+	}
+}
+
+// deployHistory returns previously deployed tags, most recent first,
+// preferring the Consul deploy ledger over ReplicaSet revisions
+func deployHistory() ([]string, error) {
+	current, err := k8sClient.CurrentTag(app, env, repo, container)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := deployment.History(app, env)
+	if err == nil && len(records) > 0 && records[0].Tag == current {
+		var tags []string
+		seen := map[string]bool{}
+		for _, r := range records {
+			if !seen[r.Tag] {
+				seen[r.Tag] = true
+				tags = append(tags, r.Tag)
+			}
+		}
+		if last := records[len(records)-1].Previous; last != "" && !seen[last] {
+			tags = append(tags, last)
+		}
+		return tags, nil
+	}
+
+	return k8sClient.TagHistory(app, env, repo, container)
+}
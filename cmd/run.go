@@ -0,0 +1,91 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deepthawtz/duncan/config"
+	"github.com/deepthawtz/duncan/k8s"
+	"github.com/spf13/cobra"
+)
+
+var preRun string
+
+// runCmd represents the run command
+var runCmd = &cobra.Command{
+	Use:   "run -- COMMAND",
+	Short: "Run a one-off task of an application",
+	Long: `Run a one-off task (e.g. a migration or backfill) as a Kubernetes Job
+created from the pod template of the application's Deployment so it runs
+the same image with the same ENV and secrets.
+
+Example:
+
+$ duncan run --app APP --env ENV -- rake db:migrate
+
+The Job's logs are streamed until it completes and the Job is deleted
+afterwards. Only the container chosen with --container (default the
+container running the app's docker repo) is run.
+
+To run a task of the new tag before every deploy set pre_deploy_run for
+the app in duncan.yml, or pass --pre-run to deploy:
+
+$ duncan deploy --app APP --env ENV --tag TAG --pre-run "rake db:migrate"
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkAppEnv(app, env)
+		if len(args) == 0 {
+			fmt.Println("must provide a command to run after --")
+			os.Exit(1)
+		}
+		checkAllowedToManage(app, env)
+		client := appClient(app, env)
+
+		command := strings.Join(args, " ")
+		if env == "production" {
+			if err := notifyApp(fmt.Sprintf(":runner: %s is running `%s` on %s", deployUser(), command, client.Cluster)); err != nil {
+				fmt.Println(err)
+			}
+		}
+		err := client.RunJob(app, env, k8s.RunOptions{
+			Command:   args,
+			Container: container,
+			Repo:      config.AppEnv(app, env).Repo,
+			Timeout:   timeout,
+			Out:       os.Stdout,
+		})
+		if err != nil {
+			fmt.Println(err)
+			if env == "production" {
+				if err := notifyApp(fmt.Sprintf(":x: `%s` run by %s on %s failed: %s", command, deployUser(), client.Cluster, err)); err != nil {
+					fmt.Println(err)
+				}
+			}
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(runCmd)
+	runCmd.Flags().StringVarP(&app, "app", "a", "", "app to run task of")
+	runCmd.Flags().StringVarP(&env, "env", "e", "", "app environment (stage, production)")
+	runCmd.Flags().StringVarP(&container, "container", "c", "", "(optional) container to run the task in (default the container running the app's docker repo)")
+	runCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "cluster to run task in if the app runs in several")
+	runCmd.Flags().DurationVar(&timeout, "timeout", 0, "how long the task may run (default 1h)")
+}
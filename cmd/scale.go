@@ -0,0 +1,158 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deepthawtz/duncan/k8s"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replicas, scaleBy int
+	scaleID           string
+	ignoreHPA         bool
+)
+
+// scaleCmd represents the scale command
+var scaleCmd = &cobra.Command{
+	Use:   "scale",
+	Short: "Change the number of replicas of an application",
+	Long: `Change the number of replicas of every Deployment and StatefulSet of
+an application, or of a single one by --id (a workload or container name
+shown by duncan list).
+
+Example:
+
+$ duncan scale --app APP --env ENV --replicas N [--id ID]
+$ duncan scale --app APP --env ENV --by +2 [--id ID]
+
+Workloads whose replica count is managed by a HorizontalPodAutoscaler are
+not scaled unless --ignore-hpa is given (the autoscaler may undo the change).
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkAppEnv(app, env)
+		relative := cmd.Flags().Changed("by")
+		if relative == cmd.Flags().Changed("replicas") {
+			fmt.Println("must provide one of --replicas or --by")
+			os.Exit(1)
+		}
+		n := replicas
+		if relative {
+			n = scaleBy
+		} else if replicas < 0 {
+			fmt.Println("--replicas must not be negative")
+			os.Exit(1)
+		}
+		checkAllowedToManage(app, env)
+		connectCluster()
+
+		changes, err := k8sClient.ScalePlan(app, env, scaleID, n, relative)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		autoscaled := false
+		for _, c := range changes {
+			if c.Autoscaler != "" {
+				fmt.Printf("%s/%s replicas are managed by horizontalpodautoscaler/%s\n", c.Kind, c.Name, c.Autoscaler)
+				autoscaled = true
+			}
+		}
+		if autoscaled && !ignoreHPA {
+			fmt.Println("refusing to scale autoscaled workloads, use --ignore-hpa to scale anyway")
+			os.Exit(1)
+		}
+		if !promptScale(changes) {
+			return
+		}
+
+		if err := k8sClient.Scale(changes); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		var scaled []string
+		for _, c := range changes {
+			scaled = append(scaled, fmt.Sprintf("%s/%s %d => %d", c.Kind, c.Name, c.From, c.To))
+		}
+		msg := fmt.Sprintf("%s :straight_ruler: *%s %s* scaled on %s by %s: %s", emoji(env), app, env, cluster, deployUser(), strings.Join(scaled, ", "))
+
+		fmt.Println("waiting for rollout to complete...")
+		rolloutErr := k8sClient.WaitForRollout(app, env, rolloutTimeout())
+		if rolloutErr != nil {
+			fmt.Println(rolloutErr)
+			msg += fmt.Sprintf(" but did not become ready: %s", rolloutErr)
+		}
+		if err := notifyApp(msg); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if rolloutErr != nil {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(scaleCmd)
+	scaleCmd.Flags().StringVarP(&app, "app", "a", "", "app to scale")
+	scaleCmd.Flags().StringVarP(&env, "env", "e", "", "app environment (stage, production)")
+	scaleCmd.Flags().IntVar(&replicas, "replicas", 0, "number of replicas to run")
+	scaleCmd.Flags().IntVar(&scaleBy, "by", 0, "number of replicas to add (e.g., +2) or remove (e.g., -1)")
+	scaleCmd.Flags().StringVar(&scaleID, "id", "", "(optional) only scale this workload or container")
+	scaleCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "cluster to use if several are configured")
+	scaleCmd.Flags().BoolVar(&ignoreHPA, "ignore-hpa", false, "scale workloads managed by a HorizontalPodAutoscaler anyway")
+	scaleCmd.Flags().BoolVarP(&force, "force", "f", false, "bypass prompt before scaling")
+	scaleCmd.Flags().DurationVar(&timeout, "timeout", 0, "how long to wait for rollout to complete (default rollout_timeout or 5m)")
+}
+
+func promptScale(changes []k8s.ScaleChange) bool {
+	if force {
+		return true
+	}
+	white := color.New(color.FgWhite, color.Bold).SprintFunc()
+	red := color.New(color.FgRed, color.Bold).SprintFunc()
+	cyan := color.New(color.FgCyan, color.Bold).SprintFunc()
+	green := color.New(color.FgGreen, color.Bold).SprintFunc()
+	yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+	fmt.Printf("You are about to scale:\n\n")
+	fmt.Printf(white("  cluster: %s\n"), white(cluster))
+	fmt.Printf(white("  app: %s\n"), yellow(app))
+	if env == "production" {
+		fmt.Printf(white("  env: %s\n"), red(env))
+	} else {
+		fmt.Printf(white("  env: %s\n"), green(env))
+	}
+	fmt.Printf(white("  replicas:\n"))
+	for _, c := range changes {
+		fmt.Printf("    %s/%s: %s => %s\n", c.Kind, c.Name, white(c.From), cyan(c.To))
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Printf(white("\nare you sure? (yes/no): "))
+	resp, _ := reader.ReadString('\n')
+
+	resp = strings.TrimSpace(resp)
+	if resp != "yes" {
+		fmt.Println("phew... that was close")
+		return false
+	}
+	return true
+}
@@ -19,6 +19,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/deepthawtz/duncan/deployment"
 	"github.com/deepthawtz/duncan/vault"
 	"github.com/spf13/cobra"
 )
@@ -57,6 +58,8 @@ var (
 		Run: func(cmd *cobra.Command, args []string) {
 			checkAppEnv(app, env)
 			validateKeyValues(args)
+			checkAllowedToManage(app, env)
+			clients := restartClients()
 
 			u := vault.SecretsURL(app, env)
 			secrets, err := vault.Read(u)
@@ -84,6 +87,9 @@ var (
 				}
 
 				printSorted(s.KVPairs)
+				if restartPods {
+					restartApp(clients, " to apply secrets changes")
+				}
 			}
 		},
 	}
@@ -94,6 +100,8 @@ var (
 		Run: func(cmd *cobra.Command, args []string) {
 			checkAppEnv(app, env)
 			validateKeys(args)
+			checkAllowedToManage(app, env)
+			clients := restartClients()
 
 			u := vault.SecretsURL(app, env)
 			secrets, err := vault.Read(u)
@@ -120,6 +128,9 @@ var (
 					fmt.Println(err)
 					os.Exit(1)
 				}
+				if restartPods {
+					restartApp(clients, " to apply secrets changes")
+				}
 			}
 		},
 	}
@@ -131,6 +142,8 @@ func init() {
 	secretsCmd.PersistentFlags().StringVarP(&app, "app", "a", "", "app to manage secrets for")
 	secretsCmd.PersistentFlags().StringVarP(&env, "env", "e", "", "app environment (stage, production)")
 	secretsSetCmd.Flags().BoolVarP(&force, "force", "f", false, "bypass prompt before setting env")
+	secretsSetCmd.Flags().BoolVar(&restartPods, "restart", false, "restart the app's pods to apply the changes")
+	secretsDelCmd.Flags().BoolVar(&restartPods, "restart", false, "restart the app's pods to apply the changes")
 	secretsCmd.AddCommand(secretsGetCmd)
 	secretsCmd.AddCommand(secretsSetCmd)
 	secretsCmd.AddCommand(secretsDelCmd)
@@ -143,6 +156,20 @@ func checkAppEnv(app, env string) {
 	}
 }
 
+// checkAllowedToManage exits unless the Consul deploy ACL allows the
+// current user to manage app/env
+func checkAllowedToManage(app, env string) {
+	allowed, err := deployment.AllowedToManage(app, env)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if !allowed {
+		fmt.Printf("permission denied: your consul_token cannot write %s which is required to manage %s %s\n", deployment.ACLKey(app, env), app, env)
+		os.Exit(1)
+	}
+}
+
 func validateKeyValues(kvs []string) {
 	if len(kvs) == 0 {
 		fmt.Println("must provide key/value pairs in KEY=VALUE format")
@@ -0,0 +1,54 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the health of an application's workloads and pods",
+	Long: `Show the workloads of an application, their pods and recent events
+in one view, e.g. to find out why a deploy is not rolling out.
+
+Example:
+
+$ duncan status --app APP --env ENV
+
+Pods show their phase, ready containers, restarts and why containers are
+not running or last terminated (e.g. CrashLoopBackOff, ImagePullBackOff or
+OOMKilled).
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		checkAppEnv(app, env)
+		client := appClient(app, env)
+		if err := client.Status(app, env); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().StringVarP(&app, "app", "a", "", "app to show status of")
+	statusCmd.Flags().StringVarP(&env, "env", "e", "", "app environment (stage, production)")
+	statusCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "cluster to show status in if the app runs in several")
+}
@@ -0,0 +1,130 @@
+// Copyright © 2020 Dylan Clendenin <dylan.clendenin@gmail.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/deepthawtz/duncan/config"
+	"github.com/deepthawtz/duncan/docker"
+	"github.com/deepthawtz/duncan/k8s"
+	"github.com/fatih/color"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var limit int
+
+// tagsCmd represents the tags command
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "List recent docker image tags available to deploy",
+	Long: `List the most recently pushed docker image tags of an application
+and mark the tag currently deployed to each environment.
+
+Example:
+
+$ duncan tags --app APP [--repo DOCKER_REPO] [--limit N]
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if app == "" {
+			fmt.Println("must provide --app flag")
+			os.Exit(1)
+		}
+		if repo == "" {
+			repo = config.AppEnv(app, "").Repo
+		}
+
+		tags, err := docker.Tags(repo, limit)
+		if err != nil {
+			fmt.Printf("could not list tags of %s: %s\n", docker.ImageName(repo), err)
+			os.Exit(1)
+		}
+
+		deployed := deployedTags()
+		green := color.New(color.FgGreen, color.Bold).SprintFunc()
+		cyan := color.New(color.FgCyan, color.Bold).SprintFunc()
+		white := color.New(color.FgWhite, color.Bold).SprintFunc()
+		yellow := color.New(color.FgYellow, color.Bold).SprintFunc()
+		var data [][]string
+		for _, t := range tags {
+			pushed := "-"
+			if !t.Pushed.IsZero() {
+				pushed = t.Pushed.Local().Format("2006-01-02 15:04:05")
+			}
+			data = append(data, []string{
+				white(t.Name),
+				cyan(pushed),
+				shortDigest(t.Digest),
+				yellow(strings.Join(deployed[t.Name], ", ")),
+			})
+		}
+
+		fmt.Println(green(docker.ImageName(repo)))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"Tag", "Pushed", "Digest", "Deployed"})
+		table.AppendBulk(data)
+		table.Render()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(tagsCmd)
+	tagsCmd.Flags().StringVarP(&app, "app", "a", "", "app to list tags for")
+	tagsCmd.Flags().StringVarP(&repo, "repo", "r", "", "(optional) if docker repo/image name differs from app name")
+	tagsCmd.Flags().IntVarP(&limit, "limit", "n", 20, "number of tags to list")
+	tagsCmd.Flags().StringSliceVar(&clusterNames, "cluster", nil, "(optional) only check these of the configured clusters")
+}
+
+// deployedTags returns the envs each tag is currently deployed to. Envs
+// deployed to several clusters are named with the cluster
+func deployedTags() map[string][]string {
+	deployed := map[string][]string{}
+	for _, e := range []string{"stage", "production"} {
+		clusters, err := k8s.AppClusters(app, e, clusterNames)
+		if err != nil {
+			fmt.Printf("WARNING: cannot show deployed tags: %s\n", err)
+			return deployed
+		}
+		for _, c := range clusters {
+			k8sClient, err := k8s.NewClusterClient(c)
+			if err != nil {
+				fmt.Printf("WARNING: cannot show deployed tags of %s: %s\n", c.Name, err)
+				continue
+			}
+			t, err := k8sClient.CurrentTag(app, e, repo, "")
+			if err != nil {
+				continue
+			}
+			name := e
+			if len(clusters) > 1 {
+				name = fmt.Sprintf("%s (%s)", e, c.Name)
+			}
+			deployed[t] = append(deployed[t], name)
+		}
+	}
+	return deployed
+}
+
+// shortDigest abbreviates a digest for display, e.g. sha256:0123456789ab
+func shortDigest(digest string) string {
+	p := strings.SplitN(digest, ":", 2)
+	if len(p) != 2 || len(p[1]) <= 12 {
+		return digest
+	}
+	return fmt.Sprintf("%s:%s", p[0], p[1][:12])
+}
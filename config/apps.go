@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+// App is the configuration of an app/env listed under apps in duncan.yml
+//
+// e.g.,
+//
+//     apps:
+//       dogfood:
+//         repo: dogfood-image
+//         github_repo: dogfood-repo
+//         namespace: dogfood
+//         envs:
+//           production:
+//             cluster: kube-us-east
+//             namespace: dogfood-production
+//       skulls: {}
+type App struct {
+	// Cluster is the kubeconfig context the app/env runs in
+	Cluster string
+	// Namespace is the Kubernetes namespace the app/env runs in
+	Namespace string
+	// Repo is the docker repo of the app, defaults to the app name
+	Repo string
+	// GithubRepo is the GitHub repo of the app, defaults to the app's entry
+	// in the older repos mapping of duncan.yml or else the app name
+	GithubRepo string
+	// PreDeployRun is a command run as a one-off Job of the new tag
+	// before each deploy, e.g. to migrate the database. It runs as the
+	// first pre_deploy job hook
+	PreDeployRun string
+	// Hooks are the hooks of the app/env by stage (see Hook)
+	Hooks map[string][]Hook
+
+	hooksErr error
+}
+
+// Apps returns the names of every app listed in duncan.yml
+func Apps() []string {
+	if _, ok := viper.Get("apps").([]interface{}); ok {
+		// apps used to be a flat list of names
+		return viper.GetStringSlice("apps")
+	}
+	var apps []string
+	for app := range viper.GetStringMap("apps") {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+	return apps
+}
+
+// AppEnv returns the configuration of an app/env. Settings under the env
+// override those of the app. env may be empty to only read app settings
+func AppEnv(app, env string) *App {
+	get := func(key string) string {
+		if env != "" {
+			if v := viper.GetString(fmt.Sprintf("apps.%s.envs.%s.%s", app, env, key)); v != "" {
+				return v
+			}
+		}
+		return viper.GetString(fmt.Sprintf("apps.%s.%s", app, key))
+	}
+	orApp := func(v string) string {
+		if v == "" {
+			return app
+		}
+		return v
+	}
+
+	githubRepo := get("github_repo")
+	if githubRepo == "" {
+		// repos mapped app names to GitHub repos before apps existed
+		githubRepo = viper.GetStringMapString("repos")[app]
+	}
+	hooks, err := appHooks(app, env)
+	return &App{
+		Cluster:      get("cluster"),
+		Namespace:    get("namespace"),
+		Repo:         orApp(get("repo")),
+		GithubRepo:   orApp(githubRepo),
+		PreDeployRun: get("pre_deploy_run"),
+		Hooks:        hooks,
+		hooksErr:     err,
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const appsYAML = `
+apps:
+  dogfood:
+    repo: dogfood-image
+    github_repo: dogfood-repo
+    namespace: dogfood
+    envs:
+      production:
+        cluster: kube-us-east
+        namespace: dogfood-production
+        pre_deploy_run: rake db:migrate
+  skulls: {}
+`
+
+func TestAppEnv(t *testing.T) {
+	v := viper.GetViper()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewBufferString(appsYAML)); err != nil {
+		t.Fatal(err)
+	}
+	defer viper.Set("apps", nil)
+
+	if apps := Apps(); !reflect.DeepEqual(apps, []string{"dogfood", "skulls"}) {
+		t.Errorf("expected apps dogfood and skulls got %v", apps)
+	}
+
+	cases := []struct {
+		app, env string
+		exp      App
+	}{
+		{app: "dogfood", env: "stage", exp: App{Namespace: "dogfood", Repo: "dogfood-image", GithubRepo: "dogfood-repo"}},
+		{app: "dogfood", env: "production", exp: App{Cluster: "kube-us-east", Namespace: "dogfood-production", Repo: "dogfood-image", GithubRepo: "dogfood-repo", PreDeployRun: "rake db:migrate"}},
+		{app: "skulls", env: "stage", exp: App{Repo: "skulls", GithubRepo: "skulls"}},
+		{app: "unlisted", env: "", exp: App{Repo: "unlisted", GithubRepo: "unlisted"}},
+	}
+	for _, test := range cases {
+		if a := AppEnv(test.app, test.env); !reflect.DeepEqual(*a, test.exp) {
+			t.Errorf("expected %s %s to be configured as %+v got %+v", test.app, test.env, test.exp, *a)
+		}
+	}
+}
+
+func TestAppsList(t *testing.T) {
+	viper.Set("apps", []interface{}{"dogfood", "skulls"})
+	defer viper.Set("apps", nil)
+
+	if apps := Apps(); !reflect.DeepEqual(apps, []string{"dogfood", "skulls"}) {
+		t.Errorf("expected apps dogfood and skulls got %v", apps)
+	}
+}
+
+func TestAppEnvLegacyRepos(t *testing.T) {
+	viper.Set("repos", map[string]interface{}{"dogfood": "dogfood-repo"})
+	viper.Set("apps", map[string]interface{}{"skulls": map[string]interface{}{"github_repo": "skulls-repo"}})
+	defer viper.Set("repos", nil)
+	defer viper.Set("apps", nil)
+
+	if repo := AppEnv("dogfood", "production").GithubRepo; repo != "dogfood-repo" {
+		t.Errorf("expected github repo from repos mapping dogfood-repo got %s", repo)
+	}
+	if repo := AppEnv("skulls", "production").GithubRepo; repo != "skulls-repo" {
+		t.Errorf("expected github_repo skulls-repo got %s", repo)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os/user"
+	"sort"
 )
 
 // Changes provides a human-readable message summarizing what env/secrets
@@ -32,3 +33,15 @@ func Changes(typ string, updated map[string][]string) string {
 	u, _ := user.Current()
 	return fmt.Sprintf("%s updated by %s:\n%s", typ, u.Username, changes)
 }
+
+// MissingKeys returns the sorted keys set in from that are not set in to
+func MissingKeys(from, to map[string]string) []string {
+	var missing []string
+	for k := range from {
+		if _, ok := to[k]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os/user"
+	"reflect"
 	"testing"
 )
 
@@ -39,3 +40,23 @@ func TestChanges(t *testing.T) {
 		}
 	}
 }
+
+func TestMissingKeys(t *testing.T) {
+	stage := map[string]string{"FOO": "1", "BAR": "2", "BAZ": "3"}
+	production := map[string]string{"FOO": "1", "QUX": "4"}
+	cases := []struct {
+		from, to map[string]string
+		missing  []string
+	}{
+		{from: stage, to: production, missing: []string{"BAR", "BAZ"}},
+		{from: production, to: stage, missing: []string{"QUX"}},
+		{from: stage, to: stage, missing: nil},
+		{from: stage, to: nil, missing: []string{"BAR", "BAZ", "FOO"}},
+	}
+	for _, test := range cases {
+		missing := MissingKeys(test.from, test.to)
+		if !reflect.DeepEqual(missing, test.missing) {
+			t.Errorf("expected %v but got %v", test.missing, missing)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// hook stages
+const (
+	PreDeploy  = "pre_deploy"
+	PostDeploy = "post_deploy"
+)
+
+// Stages are the hook stages in the order they run
+var Stages = []string{PreDeploy, PostDeploy}
+
+// preDeployRunTimeout limits the job of pre_deploy_run, as long as
+// duncan run allows by default
+const preDeployRunTimeout = time.Hour
+
+// Hook is a step run before or after deploying an app. It either runs a
+// local command, makes an HTTP request or runs a command as a Job in the
+// cluster (see k8s.RunJob). Hook fields may use the variables of
+// deployment.HookVars, e.g. {{.Tag}}
+//
+// e.g.,
+//
+//     apps:
+//       dogfood:
+//         hooks:
+//           pre_deploy:
+//             - job: rake db:migrate
+//           post_deploy:
+//             - name: smoke test
+//               command: ./smoke-test.sh {{.Env}}
+//               timeout: 10m
+//             - http:
+//                 url: https://api.fastly.com/service/xyz/purge_all
+//                 method: POST
+//                 headers:
+//                   Fastly-Key: secret
+type Hook struct {
+	Name    string
+	Command string
+	HTTP    *HTTPHook
+	Job     string
+	Timeout time.Duration
+}
+
+// HTTPHook is an HTTP request made by a hook. Method defaults to POST
+type HTTPHook struct {
+	URL     string
+	Method  string
+	Headers map[string]string
+	Body    string
+}
+
+// StageHooks returns the hooks of a stage (pre_deploy or post_deploy).
+// PreDeployRun, if set, is the first pre_deploy hook
+func (a *App) StageHooks(stage string) []Hook {
+	hooks := a.Hooks[stage]
+	if stage == PreDeploy && a.PreDeployRun != "" {
+		run := Hook{Job: a.PreDeployRun, Timeout: preDeployRunTimeout}
+		hooks = append([]Hook{run}, hooks...)
+	}
+	return hooks
+}
+
+// CheckHooks returns an error if the hooks of the app/env in duncan.yml
+// are invalid
+func (a *App) CheckHooks() error {
+	if a.hooksErr != nil {
+		return a.hooksErr
+	}
+	for _, stage := range Stages {
+		for i, h := range a.Hooks[stage] {
+			n := 0
+			if h.Command != "" {
+				n++
+			}
+			if h.HTTP != nil {
+				n++
+				if h.HTTP.URL == "" {
+					return fmt.Errorf("invalid hooks.%s in duncan.yml: http hook %d has no url", stage, i+1)
+				}
+			}
+			if h.Job != "" {
+				n++
+			}
+			if n != 1 {
+				return fmt.Errorf("invalid hooks.%s in duncan.yml: hook %d must have one of command, http or job", stage, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// appHooks reads the hooks of an app/env by stage. Hooks of a stage listed
+// under the env replace those of the app
+func appHooks(app, env string) (map[string][]Hook, error) {
+	var hooks map[string][]Hook
+	for _, stage := range Stages {
+		key := fmt.Sprintf("apps.%s.envs.%s.hooks.%s", app, env, stage)
+		if env == "" || !viper.IsSet(key) {
+			key = fmt.Sprintf("apps.%s.hooks.%s", app, stage)
+		}
+		if !viper.IsSet(key) {
+			continue
+		}
+		var stageHooks []Hook
+		if err := viper.UnmarshalKey(key, &stageHooks); err != nil {
+			return nil, fmt.Errorf("invalid %s in duncan.yml: %s", key, err)
+		}
+		if len(stageHooks) == 0 {
+			continue
+		}
+		if hooks == nil {
+			hooks = map[string][]Hook{}
+		}
+		hooks[stage] = stageHooks
+	}
+	return hooks, nil
+}
+
+// String describes a hook by name, or by what it runs if unnamed
+func (h Hook) String() string {
+	switch {
+	case h.Name != "":
+		return h.Name
+	case h.Command != "":
+		return fmt.Sprintf("command `%s`", h.Command)
+	case h.HTTP != nil:
+		return fmt.Sprintf("%s %s", h.Method(), h.HTTP.URL)
+	}
+	return fmt.Sprintf("job `%s`", h.Job)
+}
+
+// Method returns the method of an HTTP hook
+func (h Hook) Method() string {
+	if h.HTTP.Method == "" {
+		return http.MethodPost
+	}
+	return strings.ToUpper(h.HTTP.Method)
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const hooksYAML = `
+apps:
+  foo:
+    pre_deploy_run: rake db:migrate
+    hooks:
+      pre_deploy:
+        - command: ./check.sh
+      post_deploy:
+        - name: smoke test
+          command: ./smoke-test.sh
+          timeout: 10m
+        - http:
+            url: https://example.com/purge
+    envs:
+      stage:
+        hooks:
+          post_deploy: []
+  bar:
+    hooks:
+      pre_deploy:
+        - command: true
+          job: true
+  baz:
+    hooks:
+      post_deploy:
+        - http:
+            method: GET
+`
+
+func TestHooks(t *testing.T) {
+	v := viper.GetViper()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewBufferString(hooksYAML)); err != nil {
+		t.Fatal(err)
+	}
+	defer viper.Set("apps", nil)
+
+	a := AppEnv("foo", "production")
+	if err := a.CheckHooks(); err != nil {
+		t.Fatal(err)
+	}
+	hooks := a.StageHooks(PostDeploy)
+	if len(hooks) != 2 {
+		t.Fatalf("expected 2 post deploy hooks got %d", len(hooks))
+	}
+	if hooks[0].Name != "smoke test" || hooks[0].Timeout != 10*time.Minute {
+		t.Errorf("expected smoke test hook with 10m timeout got %+v", hooks[0])
+	}
+	if hooks[1].HTTP == nil || hooks[1].String() != "POST https://example.com/purge" {
+		t.Errorf("expected http hook got %+v", hooks[1])
+	}
+
+	a = AppEnv("foo", "stage")
+	if hooks := a.StageHooks(PostDeploy); len(hooks) != 0 {
+		t.Errorf("expected stage hooks to replace app hooks got %d", len(hooks))
+	}
+	// pre_deploy_run is the first pre_deploy hook
+	hooks = a.StageHooks(PreDeploy)
+	if len(hooks) != 2 || hooks[0].Job != "rake db:migrate" || hooks[0].Timeout != time.Hour || hooks[1].Command != "./check.sh" {
+		t.Errorf("expected pre_deploy_run job followed by check command got %+v", hooks)
+	}
+	if s := hooks[0].String(); s != "job `rake db:migrate`" {
+		t.Errorf("expected pre_deploy_run to be described as its job got %s", s)
+	}
+
+	if err := AppEnv("bar", "stage").CheckHooks(); err == nil {
+		t.Error("expected error for hook with command and job")
+	}
+	if err := AppEnv("baz", "stage").CheckHooks(); err == nil {
+		t.Error("expected error for http hook without url")
+	}
+	if hooks := AppEnv("unlisted", "stage").StageHooks(PreDeploy); len(hooks) != 0 {
+		t.Errorf("expected no hooks got %+v", hooks)
+	}
+}
@@ -26,6 +26,16 @@ type KVPair struct {
 	Verb  string `json:"Verb,omitempty"`
 }
 
+// NotFoundError is returned by Read when a KV prefix does not exist or
+// cannot be read with the configured token
+type NotFoundError struct {
+	URL string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("Read access to Consul KV %s denied. Either the key does not exist or your token does not have permission to access it", e.URL)
+}
+
 // Read returns ENV for given consul KV URL
 func Read(url string) (map[string]string, error) {
 	url += "?recurse"
@@ -37,7 +47,7 @@ func Read(url string) (map[string]string, error) {
 	defer resp.Body.Close()
 	var env []KVPair
 	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("Read access to Consul KV %s denied. Either the key does not exist or your token does not have permission to access it", url)
+		return nil, &NotFoundError{URL: url}
 	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to fetch ENV: %s", resp.Status)
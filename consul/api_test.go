@@ -103,7 +103,10 @@ func TestRead(t *testing.T) {
 	viper.Set("consul_token", "abc123")
 	for _, app := range apps {
 		ts := createConsulENVServer(app)
-		env, _ := Read(ts.URL)
+		env, err := Read(ts.URL)
+		if _, notFound := err.(*NotFoundError); !app.exists && !notFound {
+			t.Errorf("expected not found error but got %v", err)
+		}
 		if app.exists && len(env) == 0 {
 			t.Errorf("expected populated ENV map but got %v", env)
 		}
@@ -0,0 +1,90 @@
+package deployment
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/deepthawtz/duncan/consul"
+	"github.com/spf13/viper"
+)
+
+// Record represents a single successful deploy in the deploy ledger
+type Record struct {
+	Previous  string    `json:"previous"`
+	Tag       string    `json:"tag"`
+	Digest    string    `json:"digest,omitempty"`
+	User      string    `json:"user"`
+	Cluster   string    `json:"cluster"`
+	Timestamp time.Time `json:"timestamp"`
+	Diff      string    `json:"diff"`
+}
+
+// RecordDeploy appends a deploy record to the Consul deploy ledger
+// for an app/env
+func RecordDeploy(app, env string, r *Record) error {
+	value, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	txn := []*consul.TxnItem{
+		{
+			KV: &consul.KVPair{
+				Key:   fmt.Sprintf("%s%d", historyKey(app, env), r.Timestamp.UnixNano()),
+				Value: base64.StdEncoding.EncodeToString(value),
+				Verb:  "set",
+			},
+		},
+	}
+	body, err := json.Marshal(txn)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{}
+	url := consul.TxnURL()
+	req, _ := http.NewRequest("PUT", url, bytes.NewReader(body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to record deploy in Consul KV %s: %s", historyKey(app, env), resp.Status)
+	}
+	return nil
+}
+
+// History returns the deploy ledger for an app/env, most recent first
+func History(app, env string) ([]*Record, error) {
+	kvs, err := consul.Read(HistoryURL(app, env))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*Record
+	for k, v := range kvs {
+		r := &Record{}
+		if err := json.Unmarshal([]byte(v), r); err != nil {
+			return nil, fmt.Errorf("invalid deploy record %s%s: %s", historyKey(app, env), k, err)
+		}
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Timestamp.After(records[j].Timestamp)
+	})
+	return records, nil
+}
+
+// HistoryURL returns the Consul KV URL of the deploy ledger for an app/env
+func HistoryURL(app, env string) string {
+	host := viper.GetString("consul_host")
+	return fmt.Sprintf("%s/v1/kv/%s", host, historyKey(app, env))
+}
+
+func historyKey(app, env string) string {
+	return fmt.Sprintf("deploys/%s/%s/history/", app, env)
+}
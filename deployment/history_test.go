@@ -0,0 +1,60 @@
+package deployment
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deepthawtz/duncan/consul"
+	"github.com/spf13/viper"
+)
+
+func TestHistory(t *testing.T) {
+	now := time.Now()
+	records := []*Record{
+		{Previous: "v1.0.0", Tag: "v1.1.0", User: "tim", Cluster: "kube", Timestamp: now.Add(-2 * time.Hour)},
+		{Previous: "v1.2.0", Tag: "v1.3.0", User: "tim", Cluster: "kube", Timestamp: now},
+		{Previous: "v1.1.0", Tag: "v1.2.0", User: "tony", Cluster: "kube", Timestamp: now.Add(-time.Hour)},
+	}
+	ts := createConsulHistoryServer("foo", "stage", records)
+	defer ts.Close()
+	viper.Set("consul_host", ts.URL)
+
+	history, err := History("foo", "stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != len(records) {
+		t.Fatalf("expected %d records got %d", len(records), len(history))
+	}
+	for i, exp := range []string{"v1.3.0", "v1.2.0", "v1.1.0"} {
+		if history[i].Tag != exp {
+			t.Errorf("expected record %d to be %s got %s", i, exp, history[i].Tag)
+		}
+	}
+}
+
+func createConsulHistoryServer(app, env string, records []*Record) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != fmt.Sprintf("/v1/kv/deploys/%s/%s/history/", app, env) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var kvs []consul.KVPair
+		for _, rec := range records {
+			b, err := json.Marshal(rec)
+			if err != nil {
+				panic(err)
+			}
+			kvs = append(kvs, consul.KVPair{
+				Key:   fmt.Sprintf("deploys/%s/%s/history/%d", app, env, rec.Timestamp.UnixNano()),
+				Value: base64.StdEncoding.EncodeToString(b),
+			})
+		}
+		json.NewEncoder(w).Encode(kvs)
+	}))
+}
@@ -0,0 +1,135 @@
+package deployment
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/deepthawtz/duncan/config"
+)
+
+// defaultHookTimeout limits hooks without a timeout
+const defaultHookTimeout = 5 * time.Minute
+
+// HookVars are the variables available to hooks as template variables and
+// as DUNCAN_* environment variables of commands and jobs
+type HookVars struct {
+	App         string
+	Env         string
+	PreviousTag string
+	Tag         string
+	Diff        string
+}
+
+// Environment returns the hook variables as environment variables
+func (v HookVars) Environment() map[string]string {
+	return map[string]string{
+		"DUNCAN_APP":          v.App,
+		"DUNCAN_ENV":          v.Env,
+		"DUNCAN_PREVIOUS_TAG": v.PreviousTag,
+		"DUNCAN_TAG":          v.Tag,
+		"DUNCAN_DIFF":         v.Diff,
+	}
+}
+
+// RunHook runs a hook with vars, writing its output to out. runJob runs
+// the expanded command of job hooks in the cluster within the hook's timeout
+func RunHook(h config.Hook, vars HookVars, out io.Writer, runJob func(command string, timeout time.Duration) error) error {
+	timeout := h.Timeout
+	if timeout == 0 {
+		timeout = defaultHookTimeout
+	}
+	switch {
+	case h.Command != "":
+		command, err := expand(h.Command, vars)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+		cmd.Env = os.Environ()
+		for k, v := range vars.Environment() {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+		}
+		cmd.Stdout, cmd.Stderr = out, out
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return fmt.Errorf("%s did not finish within %s", h, timeout)
+			}
+			return fmt.Errorf("%s failed: %s", h, err)
+		}
+		return nil
+	case h.HTTP != nil:
+		return request(h, vars, timeout)
+	}
+	command, err := expand(h.Job, vars)
+	if err != nil {
+		return err
+	}
+	if err := runJob(command, timeout); err != nil {
+		return fmt.Errorf("%s failed: %s", h, err)
+	}
+	return nil
+}
+
+// request makes the HTTP request of a hook, failing unless it responds
+// with a 2xx status
+func request(h config.Hook, vars HookVars, timeout time.Duration) error {
+	url, err := expand(h.HTTP.URL, vars)
+	if err != nil {
+		return err
+	}
+	body, err := expand(h.HTTP.Body, vars)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(h.Method(), url, bytes.NewBufferString(body))
+	if err != nil {
+		return err
+	}
+	var names []string
+	for name := range h.HTTP.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := expand(h.HTTP.Headers[name], vars)
+		if err != nil {
+			return err
+		}
+		req.Header.Set(name, value)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %s", h, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s failed: %s", h, resp.Status)
+	}
+	return nil
+}
+
+// expand fills in the hook variables of a hook field
+func expand(text string, vars HookVars) (string, error) {
+	t, err := template.New("hook").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid hook template %q: %s", text, err)
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, vars); err != nil {
+		return "", fmt.Errorf("invalid hook template %q: %s", text, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,78 @@
+package deployment
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deepthawtz/duncan/config"
+)
+
+func TestHookRun(t *testing.T) {
+	vars := HookVars{App: "foo", Env: "production", PreviousTag: "v1", Tag: "v2", Diff: "https://github.com/org/foo/compare/v1...v2"}
+	noJob := func(string, time.Duration) error {
+		t.Error("expected no job to run")
+		return nil
+	}
+
+	var out bytes.Buffer
+	h := config.Hook{Command: "echo {{.App}} {{.Tag}} $DUNCAN_PREVIOUS_TAG"}
+	if err := RunHook(h, vars, &out, noJob); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "foo v2 v1" {
+		t.Errorf("expected 'foo v2 v1' got '%s'", got)
+	}
+	if err := RunHook(config.Hook{Command: "exit 1"}, vars, &out, noJob); err == nil {
+		t.Error("expected failing command to fail hook")
+	}
+	if err := RunHook(config.Hook{Command: "{{.Missing}}"}, vars, &out, noJob); err == nil {
+		t.Error("expected unknown variable to fail hook")
+	}
+
+	var body, header string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body, header = string(b), r.Header.Get("X-Env")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+	h = config.Hook{HTTP: &config.HTTPHook{URL: ts.URL + "/purge", Headers: map[string]string{"X-Env": "{{.Env}}"}, Body: `{"diff": "{{.Diff}}"}`}}
+	if err := RunHook(h, vars, &out, noJob); err != nil {
+		t.Fatal(err)
+	}
+	if body != `{"diff": "https://github.com/org/foo/compare/v1...v2"}` || header != "production" {
+		t.Errorf("unexpected request body %s and header %s", body, header)
+	}
+	h = config.Hook{HTTP: &config.HTTPHook{URL: ts.URL + "/fail"}}
+	if err := RunHook(h, vars, &out, noJob); err == nil {
+		t.Error("expected 500 response to fail hook")
+	}
+
+	var ran string
+	var ranFor time.Duration
+	runJob := func(command string, timeout time.Duration) error {
+		ran, ranFor = command, timeout
+		return nil
+	}
+	h = config.Hook{Job: "rake deploy:notify[{{.Tag}}]"}
+	if err := RunHook(h, vars, &out, runJob); err != nil {
+		t.Fatal(err)
+	}
+	if ran != "rake deploy:notify[v2]" || ranFor != defaultHookTimeout {
+		t.Errorf("expected job command rake deploy:notify[v2] within %s got %s within %s", defaultHookTimeout, ran, ranFor)
+	}
+	h.Timeout = time.Hour
+	if err := RunHook(h, vars, &out, runJob); err != nil {
+		t.Fatal(err)
+	}
+	if ranFor != time.Hour {
+		t.Errorf("expected job to run within 1h got %s", ranFor)
+	}
+}
@@ -0,0 +1,177 @@
+package deployment
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/deepthawtz/duncan/config"
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest represents a release of several apps deployed together
+//
+// e.g.,
+//
+//     name: spring release
+//     parallel: true
+//     on_failure: rollback
+//     deploys:
+//       - app: api
+//         env: production
+//         tag: v1.2.3
+//       - app: web
+//         env: production
+//         tag: v4.5.6
+//         repo: web-image
+//         depends_on: [api]
+type Manifest struct {
+	Name      string           `yaml:"name"`
+	Parallel  bool             `yaml:"parallel"`
+	OnFailure string           `yaml:"on_failure"`
+	Deploys   []*ManifestEntry `yaml:"deploys"`
+}
+
+// ManifestEntry represents a single app/env deploy in a release manifest
+type ManifestEntry struct {
+	App       string   `yaml:"app"`
+	Env       string   `yaml:"env"`
+	Tag       string   `yaml:"tag"`
+	Repo      string   `yaml:"repo"`
+	Container string   `yaml:"container"`
+	DependsOn []string `yaml:"depends_on"`
+}
+
+// Name returns the app/env group name of the entry
+func (e *ManifestEntry) Name() string {
+	return fmt.Sprintf("%s-%s", e.App, e.Env)
+}
+
+// LoadManifest reads and validates a release manifest
+func LoadManifest(path string) (*Manifest, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := yaml.UnmarshalStrict(b, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %s", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %s", path, err)
+	}
+	return m, nil
+}
+
+func (m *Manifest) validate() error {
+	if len(m.Deploys) == 0 {
+		return fmt.Errorf("no deploys listed")
+	}
+	switch m.OnFailure {
+	case "":
+		m.OnFailure = "stop"
+	case "stop", "rollback":
+	default:
+		return fmt.Errorf("on_failure must be stop or rollback, got %s", m.OnFailure)
+	}
+
+	seen := map[string]bool{}
+	for _, e := range m.Deploys {
+		if e.App == "" || e.Env == "" || e.Tag == "" {
+			return fmt.Errorf("every deploy must have app, env and tag")
+		}
+		if seen[e.Name()] {
+			return fmt.Errorf("%s is listed more than once", e.Name())
+		}
+		seen[e.Name()] = true
+		if e.Repo == "" {
+			e.Repo = config.AppEnv(e.App, e.Env).Repo
+		}
+	}
+	for _, e := range m.Deploys {
+		for _, d := range e.DependsOn {
+			if len(m.dependencies(&ManifestEntry{DependsOn: []string{d}})) == 0 {
+				return fmt.Errorf("%s depends on %s which is not listed", e.Name(), d)
+			}
+		}
+	}
+
+	_, err := m.Waves()
+	return err
+}
+
+// dependencies returns the entries an entry depends on. A dependency may
+// name an app (all of its envs) or an app-env group
+func (m *Manifest) dependencies(e *ManifestEntry) []*ManifestEntry {
+	var deps []*ManifestEntry
+	for _, d := range e.DependsOn {
+		for _, o := range m.Deploys {
+			if o != e && (o.App == d || o.Name() == d) {
+				deps = append(deps, o)
+			}
+		}
+	}
+	return deps
+}
+
+// Order returns the entries in manifest order, moving entries after
+// the entries they depend on
+func (m *Manifest) Order() ([]*ManifestEntry, error) {
+	var order []*ManifestEntry
+	done := map[*ManifestEntry]bool{}
+	for len(order) < len(m.Deploys) {
+		next := m.ready(done)
+		if len(next) == 0 {
+			return nil, m.cycleError(done)
+		}
+		done[next[0]] = true
+		order = append(order, next[0])
+	}
+	return order, nil
+}
+
+// Waves groups entries into waves that can be deployed in parallel.
+// Every entry is in a later wave than the entries it depends on
+func (m *Manifest) Waves() ([][]*ManifestEntry, error) {
+	var waves [][]*ManifestEntry
+	done := map[*ManifestEntry]bool{}
+	for n := 0; n < len(m.Deploys); {
+		wave := m.ready(done)
+		if len(wave) == 0 {
+			return nil, m.cycleError(done)
+		}
+		for _, e := range wave {
+			done[e] = true
+		}
+		n += len(wave)
+		waves = append(waves, wave)
+	}
+	return waves, nil
+}
+
+// ready returns the entries not yet done whose dependencies are all done
+func (m *Manifest) ready(done map[*ManifestEntry]bool) []*ManifestEntry {
+	var ready []*ManifestEntry
+	for _, e := range m.Deploys {
+		if done[e] {
+			continue
+		}
+		ok := true
+		for _, d := range m.dependencies(e) {
+			ok = ok && done[d]
+		}
+		if ok {
+			ready = append(ready, e)
+		}
+	}
+	return ready
+}
+
+func (m *Manifest) cycleError(done map[*ManifestEntry]bool) error {
+	var names []string
+	for _, e := range m.Deploys {
+		if !done[e] {
+			names = append(names, e.Name())
+		}
+	}
+	return fmt.Errorf("dependency cycle between %v", names)
+}
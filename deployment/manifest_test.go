@@ -0,0 +1,98 @@
+package deployment
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const manifestYAML = `
+name: test release
+parallel: true
+deploys:
+  - app: web
+    env: production
+    tag: v4.5.6
+    depends_on: [api]
+  - app: api
+    env: production
+    tag: v1.2.3
+  - app: worker
+    env: production
+    tag: v7.8.9
+    repo: worker-image
+  - app: admin
+    env: production
+    tag: v1.0.0
+    depends_on: [web-production, worker]
+`
+
+func TestLoadManifest(t *testing.T) {
+	m, err := loadTestManifest(t, manifestYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.OnFailure != "stop" {
+		t.Errorf("expected on_failure to default to stop got %s", m.OnFailure)
+	}
+	if m.Deploys[0].Repo != "web" || m.Deploys[2].Repo != "worker-image" {
+		t.Errorf("expected repo to default to app name")
+	}
+
+	waves, err := m.Waves()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]string{{"api-production", "worker-production"}, {"web-production"}, {"admin-production"}}
+	if len(waves) != len(exp) {
+		t.Fatalf("expected %d waves got %d", len(exp), len(waves))
+	}
+	for i, wave := range waves {
+		for j, e := range wave {
+			if e.Name() != exp[i][j] {
+				t.Errorf("expected %s in wave %d got %s", exp[i][j], i, e.Name())
+			}
+		}
+	}
+
+	order, err := m.Order()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range []string{"api-production", "web-production", "worker-production", "admin-production"} {
+		if order[i].Name() != name {
+			t.Errorf("expected %s at position %d got %s", name, i, order[i].Name())
+		}
+	}
+}
+
+func TestLoadManifestInvalid(t *testing.T) {
+	cases := []string{
+		`deploys: []`,
+		`deploys: [{app: api, env: production}]`,
+		`deploys: [{app: api, env: production, tag: v1, depends_on: [web]}]`,
+		`{on_failure: explode, deploys: [{app: api, env: production, tag: v1}]}`,
+		`{deploys: [{app: api, env: production, tag: v1}, {app: api, env: production, tag: v2}]}`,
+		`{deploys: [{app: api, env: production, tag: v1, depends_on: [web]}, {app: web, env: production, tag: v2, depends_on: [api]}]}`,
+		`{deploys: [{app: api, env: production, tag: v1, unknown: true}]}`,
+	}
+	for _, test := range cases {
+		if _, err := loadTestManifest(t, test); err == nil {
+			t.Errorf("expected manifest to be invalid: %s", test)
+		}
+	}
+}
+
+func loadTestManifest(t *testing.T, content string) (*Manifest, error) {
+	dir, err := ioutil.TempDir("", "duncan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "release.yml")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return LoadManifest(path)
+}
@@ -7,24 +7,26 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/deepthawtz/duncan/config"
 	"github.com/deepthawtz/duncan/consul"
 	"github.com/spf13/viper"
 )
 
 // AllowedToManage checks if user is able to manage/deploy
-// an app/env
+// an app/env by writing to the app/env ACL key in Consul.
+// A non-nil error means the check itself could not be performed
 func AllowedToManage(app, env string) (bool, error) {
 	var txn []*consul.TxnItem
 	txn = append(txn, &consul.TxnItem{
 		KV: &consul.KVPair{
-			Key:   fmt.Sprintf("deploys/%s/%s/acl_check", app, env),
+			Key:   ACLKey(app, env),
 			Value: base64.StdEncoding.EncodeToString([]byte("yes")),
 			Verb:  "set",
 		},
 	})
 	txn = append(txn, &consul.TxnItem{
 		KV: &consul.KVPair{
-			Key:  fmt.Sprintf("deploys/%s/%s/acl_check", app, env),
+			Key:  ACLKey(app, env),
 			Verb: "delete",
 		},
 	})
@@ -37,20 +39,30 @@ func AllowedToManage(app, env string) (bool, error) {
 	req, _ := http.NewRequest("PUT", url, bytes.NewReader(body))
 	resp, err := client.Do(req)
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("could not reach Consul to check deploy ACL: %s", err)
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		if err != nil {
-			return false, err
-		}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	// Consul rejects unknown tokens with 403 and transactions containing
+	// operations the token may not perform with 409
+	case http.StatusForbidden, http.StatusConflict:
 		return false, nil
+	default:
+		return false, fmt.Errorf("could not check deploy ACL %s: %s", ACLKey(app, env), resp.Status)
 	}
-	return true, nil
+}
+
+// ACLKey returns the Consul key a user must be able to write
+// in order to manage/deploy an app/env
+func ACLKey(app, env string) string {
+	return fmt.Sprintf("deploys/%s/%s/acl_check", app, env)
 }
 
 // GithubDiffLink returns a GitHub diff link to view deployment changes
-func GithubDiffLink(app, prev, tag string) string {
+// of an app/env, using its github_repo in duncan.yml if set
+func GithubDiffLink(app, env, prev, tag string) string {
 	if prev == tag || prev == "" {
 		return "no changes"
 	}
@@ -58,9 +70,6 @@ func GithubDiffLink(app, prev, tag string) string {
 	if org == "" {
 		return "no github_org set: cannot generate diff link"
 	}
-	repo := viper.GetStringMapString("repos")[app]
-	if repo == "" {
-		repo = app
-	}
+	repo := config.AppEnv(app, env).GithubRepo
 	return fmt.Sprintf("https://github.com/%s/%s/compare/%s...%s", org, repo, prev, tag)
 }
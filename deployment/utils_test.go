@@ -2,6 +2,8 @@ package deployment
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -18,7 +20,7 @@ func TestGithubDiffLink(t *testing.T) {
 		{app: "foo", prev: "v1.2.4", tag: "v1.2.3"},
 	}
 	test := cases[0]
-	d := GithubDiffLink(test.app, test.prev, test.tag)
+	d := GithubDiffLink(test.app, "production", test.prev, test.tag)
 	exp := "no github_org set: cannot generate diff link"
 	if d != exp {
 		t.Errorf("expected '%s' got '%s'", exp, d)
@@ -27,7 +29,7 @@ func TestGithubDiffLink(t *testing.T) {
 	org := "bar"
 	viper.Set("github_org", org)
 	for _, test := range cases {
-		d := GithubDiffLink(test.app, test.prev, test.tag)
+		d := GithubDiffLink(test.app, "production", test.prev, test.tag)
 		if test.prev == test.tag {
 			if d != "no changes" {
 				t.Errorf("expected no changes got %s", d)
@@ -39,4 +41,57 @@ func TestGithubDiffLink(t *testing.T) {
 			}
 		}
 	}
+
+	viper.Set("apps", map[string]interface{}{"foo": map[string]interface{}{
+		"github_repo": "foo-repo",
+		"envs":        map[string]interface{}{"stage": map[string]interface{}{"github_repo": "foo-stage-repo"}},
+	}})
+	defer viper.Set("apps", nil)
+	d = GithubDiffLink("foo", "production", "v1.2.3", "v1.2.4")
+	if exp := "https://github.com/bar/foo-repo/compare/v1.2.3...v1.2.4"; d != exp {
+		t.Errorf("expected %s but got %s", exp, d)
+	}
+	d = GithubDiffLink("foo", "stage", "v1.2.3", "v1.2.4")
+	if exp := "https://github.com/bar/foo-stage-repo/compare/v1.2.3...v1.2.4"; d != exp {
+		t.Errorf("expected %s but got %s", exp, d)
+	}
+}
+
+func TestAllowedToManage(t *testing.T) {
+	cases := []struct {
+		status  int
+		allowed bool
+		fails   bool
+	}{
+		{status: http.StatusOK, allowed: true},
+		{status: http.StatusForbidden, allowed: false},
+		{status: http.StatusConflict, allowed: false},
+		{status: http.StatusInternalServerError, allowed: false, fails: true},
+	}
+	for _, test := range cases {
+		ts := createConsulTxnServer(test.status)
+		viper.Set("consul_host", ts.URL)
+		allowed, err := AllowedToManage("foo", "production")
+		if allowed != test.allowed {
+			t.Errorf("expected allowed=%v for status %d", test.allowed, test.status)
+		}
+		if test.fails && err == nil {
+			t.Errorf("expected error for status %d", test.status)
+		}
+		if !test.fails && err != nil {
+			t.Errorf("unexpected error for status %d: %s", test.status, err)
+		}
+		ts.Close()
+	}
+
+	viper.Set("consul_host", "http://127.0.0.1:0")
+	if _, err := AllowedToManage("foo", "production"); err == nil {
+		t.Error("expected error when Consul is unreachable")
+	}
+}
+
+func createConsulTxnServer(status int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
 }
@@ -0,0 +1,100 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Image represents a parsed docker image reference such as
+// registry:5000/org/app:tag@sha256:abc123
+type Image struct {
+	// Name is the image repository including registry host if any
+	Name   string
+	Tag    string
+	Digest string
+}
+
+// ParseImage parses a docker image reference into its name, tag and digest.
+// Registry hosts with ports, untagged images and digests are supported
+func ParseImage(ref string) (*Image, error) {
+	if ref == "" || strings.ContainsAny(ref, " \t\n") {
+		return nil, fmt.Errorf("invalid image reference %q", ref)
+	}
+
+	img := &Image{Name: ref}
+	if i := strings.Index(img.Name, "@"); i >= 0 {
+		img.Name, img.Digest = img.Name[:i], img.Name[i+1:]
+		if !isDigest(img.Digest) {
+			return nil, fmt.Errorf("invalid digest in image reference %q", ref)
+		}
+	}
+	// a colon after the last slash separates the tag, any colon
+	// before it belongs to a registry host:port
+	if i := strings.LastIndex(img.Name, ":"); i > strings.LastIndex(img.Name, "/") {
+		img.Name, img.Tag = img.Name[:i], img.Name[i+1:]
+		if img.Tag == "" {
+			return nil, fmt.Errorf("empty tag in image reference %q", ref)
+		}
+	}
+	if img.Name == "" || strings.HasSuffix(img.Name, "/") {
+		return nil, fmt.Errorf("invalid image name in reference %q", ref)
+	}
+
+	return img, nil
+}
+
+// String returns the image reference as used in a Kubernetes container spec
+func (i *Image) String() string {
+	s := i.Name
+	if i.Tag != "" {
+		s += ":" + i.Tag
+	}
+	if i.Digest != "" {
+		s += "@" + i.Digest
+	}
+	return s
+}
+
+// Version returns the tag of the image, or its digest if it is untagged
+func (i *Image) Version() string {
+	if i.Tag != "" {
+		return i.Tag
+	}
+	return i.Digest
+}
+
+// WithVersion returns a copy of the image pointing at a new version.
+// version may be a tag, a digest or a tag pinned to a digest (tag@digest)
+func (i *Image) WithVersion(version string) *Image {
+	img := &Image{Name: i.Name}
+	if p := strings.SplitN(version, "@", 2); len(p) == 2 {
+		img.Tag, img.Digest = p[0], p[1]
+	} else if isDigest(version) {
+		img.Digest = version
+	} else {
+		img.Tag = version
+	}
+	return img
+}
+
+// Host returns the registry host of the image, if any
+func (i *Image) Host() string {
+	p := strings.SplitN(i.Name, "/", 2)
+	if len(p) == 2 && (strings.ContainsAny(p[0], ".:") || p[0] == "localhost") {
+		return p[0]
+	}
+	return ""
+}
+
+// Path returns the image name without registry host
+func (i *Image) Path() string {
+	if host := i.Host(); host != "" {
+		return strings.TrimPrefix(i.Name, host+"/")
+	}
+	return i.Name
+}
+
+func isDigest(s string) bool {
+	p := strings.SplitN(s, ":", 2)
+	return len(p) == 2 && p[0] != "" && p[1] != "" && !strings.ContainsAny(p[1], ":/")
+}
@@ -0,0 +1,66 @@
+package docker
+
+import "testing"
+
+func TestParseImage(t *testing.T) {
+	cases := []struct {
+		ref    string
+		name   string
+		tag    string
+		digest string
+		host   string
+		fails  bool
+	}{
+		{ref: "foo", name: "foo"},
+		{ref: "foo:1.2.3", name: "foo", tag: "1.2.3"},
+		{ref: "quay.io/myorg/foo:1.2.3", name: "quay.io/myorg/foo", tag: "1.2.3", host: "quay.io"},
+		{ref: "registry:5000/org/app", name: "registry:5000/org/app", host: "registry:5000"},
+		{ref: "registry:5000/org/app:v1", name: "registry:5000/org/app", tag: "v1", host: "registry:5000"},
+		{ref: "org/app@sha256:abc123", name: "org/app", digest: "sha256:abc123"},
+		{ref: "registry:5000/org/app:v1@sha256:abc123", name: "registry:5000/org/app", tag: "v1", digest: "sha256:abc123", host: "registry:5000"},
+		{ref: "localhost/app:v1", name: "localhost/app", tag: "v1", host: "localhost"},
+		{ref: "", fails: true},
+		{ref: "foo:", fails: true},
+		{ref: "foo@bar", fails: true},
+		{ref: "registry:5000/", fails: true},
+	}
+	for _, test := range cases {
+		img, err := ParseImage(test.ref)
+		if test.fails {
+			if err == nil {
+				t.Errorf("expected %q to fail parsing", test.ref)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error parsing %q: %s", test.ref, err)
+			continue
+		}
+		if img.Name != test.name || img.Tag != test.tag || img.Digest != test.digest {
+			t.Errorf("expected %q to parse to %s/%s/%s got %+v", test.ref, test.name, test.tag, test.digest, img)
+		}
+		if img.Host() != test.host {
+			t.Errorf("expected host %q for %q got %q", test.host, test.ref, img.Host())
+		}
+		if img.String() != test.ref {
+			t.Errorf("expected %q to round trip got %q", test.ref, img.String())
+		}
+	}
+}
+
+func TestWithVersion(t *testing.T) {
+	img, _ := ParseImage("registry:5000/org/app:v1@sha256:abc123")
+	cases := []struct {
+		version string
+		exp     string
+	}{
+		{version: "v2", exp: "registry:5000/org/app:v2"},
+		{version: "sha256:def456", exp: "registry:5000/org/app@sha256:def456"},
+		{version: "v2@sha256:def456", exp: "registry:5000/org/app:v2@sha256:def456"},
+	}
+	for _, test := range cases {
+		if s := img.WithVersion(test.version).String(); s != test.exp {
+			t.Errorf("expected %s got %s", test.exp, s)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TagResponse represents a Quay API tags response
+type TagResponse struct {
+	Tags []struct {
+		Name           string `json:"name"`
+		ManifestDigest string `json:"manifest_digest"`
+		StartTS        int64  `json:"start_ts"`
+	} `json:"tags"`
+}
+
+// quayRegistry speaks the Quay.io v1 API
+type quayRegistry struct {
+	host, namespace, token string
+}
+
+func (q *quayRegistry) VerifyTagExists(repo, tag string) error {
+	_, err := q.fetchTag(repo, tag)
+	return err
+}
+
+func (q *quayRegistry) TagDigest(repo, tag string) (string, error) {
+	tr, err := q.fetchTag(repo, tag)
+	if err != nil {
+		return "", err
+	}
+	digest := tr.Tags[0].ManifestDigest
+	if digest == "" {
+		return "", fmt.Errorf("no digest found for tag %s", tag)
+	}
+	return digest, nil
+}
+
+func (q *quayRegistry) Tags(repo string, limit int) ([]*Tag, error) {
+	u := fmt.Sprintf("%s/api/v1/repository/%s/tag/?onlyActiveTags=true&limit=%d", q.host, repoPath(q.namespace, repo), limit)
+	tr := &TagResponse{}
+	if err := q.get(u, tr); err != nil {
+		return nil, err
+	}
+
+	var tags []*Tag
+	for _, t := range tr.Tags {
+		tag := &Tag{Name: t.Name, Digest: t.ManifestDigest}
+		if t.StartTS > 0 {
+			tag.Pushed = time.Unix(t.StartTS, 0)
+		}
+		tags = append(tags, tag)
+	}
+	sortTags(tags)
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+func (q *quayRegistry) fetchTag(repo, tag string) (*TagResponse, error) {
+	tr := &TagResponse{}
+	if err := q.get(q.tagsURL(repo, tag), tr); err != nil {
+		return nil, err
+	}
+	if len(tr.Tags) == 0 {
+		return nil, fmt.Errorf("failed to find tag")
+	}
+	return tr, nil
+}
+
+func (q *quayRegistry) get(url string, v interface{}) error {
+	client := &http.Client{}
+	req, _ := http.NewRequest("GET", url, strings.NewReader(""))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", q.token))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to find tag: %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (q *quayRegistry) tagsURL(repo, tag string) string {
+	return fmt.Sprintf("%s/api/v1/repository/%s/tag/?specificTag=%s", q.host, repoPath(q.namespace, repo), tag)
+}
@@ -0,0 +1,114 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Registry is a docker registry API used to look up image tags
+type Registry interface {
+	// VerifyTagExists checks if a docker tag exists for a given repo
+	VerifyTagExists(repo, tag string) error
+
+	// TagDigest returns the manifest digest a docker tag points to
+	TagDigest(repo, tag string) (string, error)
+
+	// Tags returns up to limit of the most recently pushed tags for a repo
+	Tags(repo string, limit int) ([]*Tag, error)
+}
+
+// Tag represents a docker image tag in a registry
+type Tag struct {
+	Name   string
+	Digest string
+	// Pushed is when the tag was last pushed, zero if unknown
+	Pushed time.Time
+}
+
+// NewRegistry returns the Registry configured by docker_registry_type
+// in duncan.yml. Supported types are:
+//
+//     quay  Quay.io API (default), authenticated with quay_token
+//     v2    Docker Registry HTTP API v2 w/ token auth
+//     ghcr  GitHub Container Registry (v2 w/ token auth)
+//     ecr   Docker Registry HTTP API v2 w/ basic auth (e.g., Amazon ECR)
+//
+// docker_registry_username and docker_registry_password are used to
+// authenticate against v2, ghcr and ecr registries
+func NewRegistry() (Registry, error) {
+	typ := viper.GetString("docker_registry_type")
+	host := viper.GetString("docker_registry_host")
+	namespace := registryNamespace()
+	username := viper.GetString("docker_registry_username")
+	password := viper.GetString("docker_registry_password")
+
+	switch typ {
+	case "", "quay":
+		if host == "" {
+			host = "https://quay.io"
+		}
+		return &quayRegistry{
+			host:      registryURL(host),
+			namespace: namespace,
+			token:     viper.GetString("quay_token"),
+		}, nil
+	case "v2", "ghcr", "ecr":
+		if host == "" && typ == "ghcr" {
+			host = "https://ghcr.io"
+		}
+		if host == "" {
+			return nil, fmt.Errorf("must supply docker_registry_host in duncan.yml for %s registry", typ)
+		}
+		return &v2Registry{
+			host:      registryURL(host),
+			namespace: namespace,
+			username:  username,
+			password:  password,
+			basicAuth: typ == "ecr",
+			tokens:    map[string]string{},
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported docker_registry_type: %s", typ)
+	}
+}
+
+// registryNamespace returns the registry namespace (org) images live in.
+// Defaults to the path of docker_repo_prefix, e.g. quay.io/myorg => myorg
+func registryNamespace() string {
+	if ns := viper.GetString("docker_registry_namespace"); ns != "" {
+		return ns
+	}
+	prefix := strings.Trim(viper.GetString("docker_repo_prefix"), "/")
+	img := &Image{Name: prefix}
+	if img.Host() == "" {
+		return prefix
+	}
+	return img.Path()
+}
+
+// repoPath returns the path of a repo within a registry namespace
+func repoPath(namespace, repo string) string {
+	if namespace == "" || namespace == repo {
+		return repo
+	}
+	return fmt.Sprintf("%s/%s", namespace, repo)
+}
+
+func registryURL(host string) string {
+	host = strings.TrimSuffix(host, "/")
+	if strings.HasPrefix(host, "http://") || strings.HasPrefix(host, "https://") {
+		return host
+	}
+	return "https://" + host
+}
+
+// sortTags orders tags most recently pushed first
+func sortTags(tags []*Tag) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].Pushed.After(tags[j].Pushed)
+	})
+}
@@ -0,0 +1,212 @@
+package docker
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestNewRegistry(t *testing.T) {
+	cases := []struct {
+		typ   string
+		fails bool
+	}{
+		{typ: ""},
+		{typ: "quay"},
+		{typ: "v2"},
+		{typ: "ghcr"},
+		{typ: "ecr"},
+		{typ: "artifactory", fails: true},
+	}
+	viper.Set("docker_registry_host", "registry.example.com")
+	for _, test := range cases {
+		viper.Set("docker_registry_type", test.typ)
+		_, err := NewRegistry()
+		if test.fails && err == nil {
+			t.Errorf("expected error for registry type %q", test.typ)
+		}
+		if !test.fails && err != nil {
+			t.Errorf("unexpected error for registry type %q: %s", test.typ, err)
+		}
+	}
+	viper.Set("docker_registry_type", "")
+	viper.Set("docker_registry_host", "")
+}
+
+func TestRegistryNamespace(t *testing.T) {
+	cases := []struct {
+		prefix    string
+		namespace string
+	}{
+		{prefix: "quay.io/myorg", namespace: "myorg"},
+		{prefix: "registry:5000/myorg/team/", namespace: "myorg/team"},
+		{prefix: "myorg", namespace: "myorg"},
+	}
+	for _, test := range cases {
+		viper.Set("docker_repo_prefix", test.prefix)
+		if ns := registryNamespace(); ns != test.namespace {
+			t.Errorf("expected namespace %s for %s got %s", test.namespace, test.prefix, ns)
+		}
+	}
+	viper.Set("docker_repo_prefix", "")
+}
+
+func TestParseChallenge(t *testing.T) {
+	p := parseChallenge(`Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:myorg/foo:pull"`)
+	exp := map[string]string{
+		"realm":   "https://ghcr.io/token",
+		"service": "ghcr.io",
+		"scope":   "repository:myorg/foo:pull",
+	}
+	for k, v := range exp {
+		if p[k] != v {
+			t.Errorf("expected %s=%s got %s", k, v, p[k])
+		}
+	}
+	if len(parseChallenge(`Basic realm="registry"`)) != 0 {
+		t.Error("expected basic challenge to be ignored")
+	}
+}
+
+func TestV2RegistryTagDigest(t *testing.T) {
+	cases := []struct {
+		basicAuth bool
+		tag       string
+		exists    bool
+	}{
+		{basicAuth: false, tag: "1.2.3", exists: true},
+		{basicAuth: false, tag: "4.5.6", exists: false},
+		{basicAuth: true, tag: "1.2.3", exists: true},
+		{basicAuth: true, tag: "4.5.6", exists: false},
+	}
+	for _, test := range cases {
+		ts := createV2RegistryServer("myorg/foo", "1.2.3", test.basicAuth)
+		r := &v2Registry{
+			host:      ts.URL,
+			namespace: "myorg",
+			username:  "tim",
+			password:  "duncan",
+			basicAuth: test.basicAuth,
+			tokens:    map[string]string{},
+		}
+		digest, err := r.TagDigest("foo", test.tag)
+		if test.exists && (err != nil || digest != "sha256:abc123") {
+			t.Errorf("expected tag %s to resolve to sha256:abc123 got %s (%v)", test.tag, digest, err)
+		}
+		if !test.exists && err == nil {
+			t.Errorf("did not expect tag %s to exist", test.tag)
+		}
+		ts.Close()
+	}
+}
+
+// createV2RegistryServer fakes a registry requiring either basic auth
+// or a bearer token issued by its /token endpoint
+func createV2RegistryServer(path, tag string, basicAuth bool) *httptest.Server {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if u, p, ok := r.BasicAuth(); !ok || u != "tim" || p != "duncan" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, `{"token": "t0k3n"}`)
+			return
+		}
+
+		authorized := r.Header.Get("Authorization") == "Bearer t0k3n"
+		if basicAuth {
+			u, p, ok := r.BasicAuth()
+			authorized = ok && u == "tim" && p == "duncan"
+		}
+		if !authorized {
+			if !basicAuth {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, ts.URL))
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != fmt.Sprintf("/v2/%s/manifests/%s", path, tag) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:abc123")
+		w.WriteHeader(http.StatusOK)
+	}))
+	return ts
+}
+
+func TestV2RegistryTags(t *testing.T) {
+	// tags/list is ordered by name so v9 and the sha tag are listed after
+	// the newer v10, across two pages
+	pushed := map[string]string{
+		"v10":     "2020-06-03T00:00:00Z",
+		"v8":      "2020-06-01T00:00:00Z",
+		"v9":      "2020-06-02T00:00:00Z",
+		"abc1234": "2020-05-01T00:00:00Z",
+	}
+	pages := map[string]string{
+		"":    `{"tags": ["abc1234", "v10"]}`,
+		"v10": `{"tags": ["v8", "v9"]}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/myorg/foo/tags/list":
+			last := r.URL.Query().Get("last")
+			if last == "" {
+				w.Header().Set("Link", `</v2/myorg/foo/tags/list?last=v10&n=2>; rel="next"`)
+			}
+			io.WriteString(w, pages[last])
+		case strings.HasPrefix(r.URL.Path, "/v2/myorg/foo/manifests/sha256:"):
+			tag := strings.TrimPrefix(r.URL.Path, "/v2/myorg/foo/manifests/sha256:")
+			fmt.Fprintf(w, `{"config": {"digest": "sha256:config-%s"}}`, tag)
+		case strings.HasPrefix(r.URL.Path, "/v2/myorg/foo/manifests/"):
+			tag := strings.TrimPrefix(r.URL.Path, "/v2/myorg/foo/manifests/")
+			w.Header().Set("Docker-Content-Digest", "sha256:"+tag)
+		case strings.HasPrefix(r.URL.Path, "/v2/myorg/foo/blobs/sha256:config-"):
+			tag := strings.TrimPrefix(r.URL.Path, "/v2/myorg/foo/blobs/sha256:config-")
+			fmt.Fprintf(w, `{"created": "%s"}`, pushed[tag])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	r := &v2Registry{host: ts.URL, namespace: "myorg", tokens: map[string]string{}}
+	tags, err := r.Tags("foo", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	if exp := []string{"v10", "v9", "v8"}; strings.Join(names, ",") != strings.Join(exp, ",") {
+		t.Errorf("expected newest tags %v got %v", exp, names)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	cases := []struct {
+		header, exp string
+	}{
+		{header: "", exp: ""},
+		{header: `</v2/foo/tags/list?last=b&n=2>; rel="next"`, exp: "https://registry.example.com/v2/foo/tags/list?last=b&n=2"},
+		{header: `<https://other.example.com/v2/foo/tags/list?last=b>; rel="next"`, exp: "https://other.example.com/v2/foo/tags/list?last=b"},
+		{header: `</v2/foo/tags/list?last=b>; rel="prev"`, exp: ""},
+	}
+	for _, test := range cases {
+		next, err := nextLink("https://registry.example.com/v2/foo/tags/list?n=2", test.header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next != test.exp {
+			t.Errorf("expected %q got %q", test.exp, next)
+		}
+	}
+}
@@ -1,50 +1,45 @@
 package docker
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"strings"
 
 	"github.com/spf13/viper"
 )
 
-// TagResponse represents a Quay API tags response
-type TagResponse struct {
-	Tags []struct {
-		Name string `json:"name"`
-	} `json:"tags"`
-}
-
 // VerifyTagExists checks if a docker tag exists for a given repo
 func VerifyTagExists(app, tag string) error {
-	url := tagsURL(app, tag)
-	client := &http.Client{}
-	req, _ := http.NewRequest("GET", url, strings.NewReader(""))
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", viper.GetString("quay_token")))
-	resp, err := client.Do(req)
+	r, err := NewRegistry()
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to find tag: %s", resp.Status)
-	}
+	return r.VerifyTagExists(app, tag)
+}
 
-	tr := &TagResponse{}
-	if err := json.NewDecoder(resp.Body).Decode(tr); err != nil {
-		return err
+// TagDigest returns the manifest digest a docker tag currently points to
+func TagDigest(app, tag string) (string, error) {
+	r, err := NewRegistry()
+	if err != nil {
+		return "", err
 	}
-	if len(tr.Tags) == 0 {
-		return fmt.Errorf("failed to find tag")
+	return r.TagDigest(app, tag)
+}
+
+// Tags returns up to limit of the most recently pushed tags for a repo
+func Tags(app string, limit int) ([]*Tag, error) {
+	r, err := NewRegistry()
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return r.Tags(app, limit)
 }
 
-func tagsURL(app, tag string) string {
-	host := viper.GetString("docker_registry_host")
-	if host == "" {
-		host = "https://quay.io"
+// ImageName returns the full docker image name (without tag) for a repo
+// using docker_repo_prefix if set
+func ImageName(repo string) string {
+	prefix := strings.TrimSuffix(viper.GetString("docker_repo_prefix"), "/")
+	if prefix == "" {
+		return repo
 	}
-	return fmt.Sprintf("%s/api/v1/repository/betterdoctor/%s/tag/?specificTag=%s", host, app, tag)
+	return fmt.Sprintf("%s/%s", prefix, repo)
 }
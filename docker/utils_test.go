@@ -32,6 +32,35 @@ func TestVerifyTagExists(t *testing.T) {
 	}
 }
 
+func TestTagDigest(t *testing.T) {
+	ts := createQuayAPIServer("1.2.3", true)
+	defer ts.Close()
+	viper.Set("docker_registry_host", ts.URL)
+	digest, err := TagDigest("foo", "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest != "sha256:abc123" {
+		t.Errorf("expected digest sha256:abc123 got %s", digest)
+	}
+}
+
+func TestTags(t *testing.T) {
+	ts := createQuayAPIServer("1.2.3", true)
+	defer ts.Close()
+	viper.Set("docker_registry_host", ts.URL)
+	tags, err := Tags("foo", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Name != "1.2.3" || tags[0].Digest != "sha256:abc123" {
+		t.Errorf("expected tag 1.2.3 (sha256:abc123) got %+v", tags)
+	}
+	if tags[0].Pushed.Unix() != 1593626793 {
+		t.Errorf("expected push time from start_ts got %s", tags[0].Pushed)
+	}
+}
+
 func createQuayAPIServer(tag string, exists bool) *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !exists {
@@ -39,7 +68,7 @@ func createQuayAPIServer(tag string, exists bool) *httptest.Server {
 			return
 		}
 		w.WriteHeader(http.StatusOK)
-		j := `{"tags":[{"name": "%s"}]}`
+		j := `{"tags":[{"name": "%s", "manifest_digest": "sha256:abc123", "start_ts": 1593626793}]}`
 		io.WriteString(w, fmt.Sprintf(j, tag))
 	}))
 }
@@ -0,0 +1,309 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// v2TagWorkers caps how many tags are inspected for digest and push time
+// at once since the v2 API requires several requests per tag
+const v2TagWorkers = 8
+
+// v2TagPageSize is how many tag names are requested per page of tags/list
+const v2TagPageSize = 1000
+
+// manifestMediaTypes are the manifest formats accepted from v2 registries
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+}
+
+// v2Registry speaks the Docker Registry HTTP API v2. Requests are
+// authenticated with basic auth or with bearer tokens obtained from
+// the registry's token service
+type v2Registry struct {
+	host, namespace    string
+	username, password string
+	basicAuth          bool
+
+	// tokens caches bearer tokens by scope
+	tokens map[string]string
+	mux    sync.Mutex
+}
+
+func (r *v2Registry) VerifyTagExists(repo, tag string) error {
+	_, err := r.TagDigest(repo, tag)
+	return err
+}
+
+func (r *v2Registry) TagDigest(repo, tag string) (string, error) {
+	u := fmt.Sprintf("%s/v2/%s/manifests/%s", r.host, repoPath(r.namespace, repo), tag)
+	resp, err := r.do("HEAD", u, repo)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to find tag: %s", resp.Status)
+	}
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("no digest found for tag %s", tag)
+	}
+	return digest, nil
+}
+
+func (r *v2Registry) Tags(repo string, limit int) ([]*Tag, error) {
+	names, err := r.tagNames(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	// tags/list is ordered by name, not push time, so every tag must be
+	// inspected to find the most recently pushed ones
+	tags := make([]*Tag, len(names))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < v2TagWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				tags[i] = r.tagDetails(repo, names[i])
+			}
+		}()
+	}
+	for i := range names {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	sortTags(tags)
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+// tagNames lists every tag of a repo, following the Link header of each
+// page of tags/list to the next
+func (r *v2Registry) tagNames(repo string) ([]string, error) {
+	var names []string
+	u := fmt.Sprintf("%s/v2/%s/tags/list?n=%d", r.host, repoPath(r.namespace, repo), v2TagPageSize)
+	for u != "" {
+		resp, err := r.do("GET", u, repo)
+		if err != nil {
+			return nil, err
+		}
+		list := struct {
+			Tags []string `json:"tags"`
+		}{}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("registry request %s failed: %s", u, resp.Status)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&list)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, list.Tags...)
+
+		next, err := nextLink(u, resp.Header.Get("Link"))
+		if err != nil {
+			return nil, err
+		}
+		u = next
+	}
+	return names, nil
+}
+
+// nextLink resolves the next page URL of a Link header against the URL of
+// the current page, e.g. </v2/myorg/foo/tags/list?last=v9&n=1000>; rel="next"
+func nextLink(current, header string) (string, error) {
+	for _, link := range strings.Split(header, ",") {
+		p := strings.Split(link, ";")
+		if len(p) < 2 || !strings.Contains(strings.Join(p[1:], ";"), `rel="next"`) {
+			continue
+		}
+		ref, err := url.Parse(strings.Trim(strings.TrimSpace(p[0]), "<>"))
+		if err != nil {
+			return "", fmt.Errorf("invalid Link header %q: %s", header, err)
+		}
+		base, err := url.Parse(current)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+	return "", nil
+}
+
+// tagDetails looks up the digest and push time of a tag. Push time is
+// read from the image config so is unknown for multi-arch manifest lists
+func (r *v2Registry) tagDetails(repo, name string) *Tag {
+	tag := &Tag{Name: name}
+	path := repoPath(r.namespace, repo)
+	digest, err := r.TagDigest(repo, name)
+	if err != nil {
+		return tag
+	}
+	tag.Digest = digest
+
+	manifest := struct {
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}{}
+	if err := r.getJSON(fmt.Sprintf("%s/v2/%s/manifests/%s", r.host, path, digest), repo, &manifest); err != nil || manifest.Config.Digest == "" {
+		return tag
+	}
+	config := struct {
+		Created time.Time `json:"created"`
+	}{}
+	if err := r.getJSON(fmt.Sprintf("%s/v2/%s/blobs/%s", r.host, path, manifest.Config.Digest), repo, &config); err != nil {
+		return tag
+	}
+	tag.Pushed = config.Created
+	return tag
+}
+
+func (r *v2Registry) getJSON(u, repo string, v interface{}) error {
+	resp, err := r.do("GET", u, repo)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry request %s failed: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// do performs an authenticated request against the registry, answering
+// a bearer token challenge if the registry responds with one
+func (r *v2Registry) do(method, u, repo string) (*http.Response, error) {
+	scope := fmt.Sprintf("repository:%s:pull", repoPath(r.namespace, repo))
+	r.mux.Lock()
+	cached := r.tokens[scope]
+	r.mux.Unlock()
+	resp, err := r.request(method, u, cached)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized || r.basicAuth {
+		return resp, nil
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	token, err := r.fetchToken(challenge, scope)
+	if err != nil {
+		return nil, err
+	}
+	r.mux.Lock()
+	r.tokens[scope] = token
+	r.mux.Unlock()
+	return r.request(method, u, token)
+}
+
+func (r *v2Registry) request(method, u, token string) (*http.Response, error) {
+	client := &http.Client{}
+	req, _ := http.NewRequest(method, u, strings.NewReader(""))
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	} else if r.basicAuth {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	return client.Do(req)
+}
+
+// fetchToken requests a bearer token from the token service named
+// in a WWW-Authenticate challenge
+func (r *v2Registry) fetchToken(challenge, scope string) (string, error) {
+	params := parseChallenge(challenge)
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("registry did not provide a token realm: %q", challenge)
+	}
+	q := url.Values{}
+	if service := params["service"]; service != "" {
+		q.Set("service", service)
+	}
+	if s := params["scope"]; s != "" {
+		scope = s
+	}
+	q.Set("scope", scope)
+
+	client := &http.Client{}
+	req, _ := http.NewRequest("GET", fmt.Sprintf("%s?%s", realm, q.Encode()), strings.NewReader(""))
+	if r.username != "" || r.password != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to authenticate with registry: %s", resp.Status)
+	}
+
+	tr := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", err
+	}
+	if tr.Token != "" {
+		return tr.Token, nil
+	}
+	if tr.AccessToken != "" {
+		return tr.AccessToken, nil
+	}
+	return "", fmt.Errorf("registry token service returned no token")
+}
+
+// parseChallenge parses the parameters of a bearer WWW-Authenticate header
+// e.g., Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseChallenge(challenge string) map[string]string {
+	params := map[string]string{}
+	p := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	if len(p) != 2 || !strings.EqualFold(p[0], "bearer") {
+		return params
+	}
+	s := p[1]
+	for s != "" {
+		eq := strings.Index(s, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+		var val string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end < 0 {
+				break
+			}
+			val, s = s[1:end+1], s[end+2:]
+		} else if comma := strings.Index(s, ","); comma >= 0 {
+			val, s = s[:comma], s[comma:]
+		} else {
+			val, s = s, ""
+		}
+		params[strings.ToLower(key)] = val
+		s = strings.TrimLeft(s, ", ")
+	}
+	return params
+}
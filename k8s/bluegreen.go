@@ -0,0 +1,291 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// colorLabel selects which of the blue/green Deployments a Service routes to
+const colorLabel = "color"
+
+// ActiveColor returns the color (blue or green) the app/env group's
+// Services currently route traffic to
+func (k *KubeAPI) ActiveColor(app, env string) (string, error) {
+	services, err := k.groupServices(app, env)
+	if err != nil {
+		return "", err
+	}
+	if len(services) == 0 {
+		return "", fmt.Errorf("no services select %s=%s-%s", groupLabel(), app, env)
+	}
+
+	var active string
+	for _, svc := range services {
+		color := svc.Spec.Selector[colorLabel]
+		if color != "blue" && color != "green" {
+			return "", fmt.Errorf("service/%s does not select pods by %s=blue|green, blue/green deploys are not set up for %s-%s", svc.Name, colorLabel, app, env)
+		}
+		if active != "" && color != active {
+			return "", fmt.Errorf("services of %s-%s route to different colors", app, env)
+		}
+		active = color
+	}
+	return active, nil
+}
+
+// BlueGreenTag returns the docker image tag of the active color
+func (k *KubeAPI) BlueGreenTag(app, env, repo, container string) (string, error) {
+	active, err := k.ActiveColor(app, env)
+	if err != nil {
+		return "", err
+	}
+	deployments, err := k.colorDeployments(app, env, active)
+	if err != nil {
+		return "", err
+	}
+	for _, d := range deployments {
+		if tag := findTag(repo, container, d.Spec.Template); tag != "" {
+			return tag, nil
+		}
+	}
+	return "", fmt.Errorf("could not find tag of %s deployments for %s-%s", active, app, env)
+}
+
+// BlueGreenPlan returns the inactive color and the container images that a
+// blue/green deploy of tag would run there
+func (k *KubeAPI) BlueGreenPlan(app, env, tag, repo, container string) (string, []ContainerChange, error) {
+	active, err := k.ActiveColor(app, env)
+	if err != nil {
+		return "", nil, err
+	}
+	deployments, err := k.colorDeployments(app, env, active)
+	if err != nil {
+		return "", nil, err
+	}
+
+	inactive := OtherColor(active)
+	existing, err := k.colorDeployments(app, env, inactive)
+	if err != nil {
+		return "", nil, err
+	}
+	var changes []ContainerChange
+	for _, d := range deployments {
+		c := colorDeployment(d, active, inactive, existing)
+		changes = append(changes, setImage("deployment", c.Name, &c.Spec.Template, tag, repo, container)...)
+	}
+	if len(changes) == 0 {
+		return "", nil, fmt.Errorf("no containers running %s found in %s deployments of %s-%s", repo, active, app, env)
+	}
+	return inactive, changes, nil
+}
+
+// DeployBlueGreen deploys tag to the inactive color's Deployments (creating
+// them from the active color if needed), waits for them to be fully
+// available and then switches the group's Services over to them. The
+// previously active color is left running so traffic can be switched back
+func (k *KubeAPI) DeployBlueGreen(app, env, tag, repo, container string, timeout time.Duration) error {
+	active, err := k.ActiveColor(app, env)
+	if err != nil {
+		return err
+	}
+	deployments, err := k.colorDeployments(app, env, active)
+	if err != nil {
+		return err
+	}
+
+	inactive := OtherColor(active)
+	existing, err := k.colorDeployments(app, env, inactive)
+	if err != nil {
+		return err
+	}
+	client := k.Client.AppsV1().Deployments(k.Namespace)
+	var names []string
+	for _, d := range deployments {
+		c := colorDeployment(d, active, inactive, existing)
+		setImage("deployment", c.Name, &c.Spec.Template, tag, repo, container)
+		names = append(names, c.Name)
+
+		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			existing, err := client.Get(context.Background(), c.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				fmt.Printf("creating deployment/%s\n", c.Name)
+				_, err = client.Create(context.Background(), c, metav1.CreateOptions{})
+				return err
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Printf("updating deployment/%s\n", c.Name)
+			existing.Spec.Replicas = c.Spec.Replicas
+			existing.Spec.Template = c.Spec.Template
+			_, err = client.Update(context.Background(), existing, metav1.UpdateOptions{})
+			return err
+		})
+		if retryErr != nil {
+			return fmt.Errorf("deploy failed: %v", retryErr)
+		}
+	}
+
+	if err := k.waitForDeployments(names, timeout); err != nil {
+		return err
+	}
+	return k.switchServices(app, env, inactive)
+}
+
+// SwitchColor points the app/env group's Services at the inactive color,
+// which must be fully available, and returns the newly active color
+func (k *KubeAPI) SwitchColor(app, env string) (string, error) {
+	active, err := k.ActiveColor(app, env)
+	if err != nil {
+		return "", err
+	}
+	inactive := OtherColor(active)
+	deployments, err := k.colorDeployments(app, env, inactive)
+	if err != nil {
+		return "", err
+	}
+	if len(deployments) == 0 {
+		return "", fmt.Errorf("no %s deployments found for %s-%s", inactive, app, env)
+	}
+	for _, d := range deployments {
+		msg, ok, err := deploymentRolloutStatus(d)
+		if err != nil {
+			return "", err
+		}
+		if !ok || d.Status.AvailableReplicas == 0 {
+			return "", fmt.Errorf("deployment/%s is not ready to receive traffic: %s", d.Name, msg)
+		}
+	}
+	return inactive, k.switchServices(app, env, inactive)
+}
+
+// waitForDeployments waits for the named Deployments to be fully rolled out
+func (k *KubeAPI) waitForDeployments(names []string, timeout time.Duration) error {
+	client := k.Client.AppsV1().Deployments(k.Namespace)
+	progress := map[string]string{}
+	err := wait.PollImmediate(rolloutInterval, timeout, func() (bool, error) {
+		done := true
+		for _, name := range names {
+			d, err := client.Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			msg, ok, err := deploymentRolloutStatus(*d)
+			if err != nil {
+				return false, err
+			}
+			if progress[name] != msg {
+				fmt.Printf("  %s: %s\n", cyan("deployment/"+name), msg)
+				progress[name] = msg
+			}
+			done = done && ok
+		}
+		return done, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("rollout of %s did not complete within %s", strings.Join(names, ", "), timeout)
+	}
+	return err
+}
+
+// switchServices sets the color selector of the group's Services
+func (k *KubeAPI) switchServices(app, env, color string) error {
+	services, err := k.groupServices(app, env)
+	if err != nil {
+		return err
+	}
+	client := k.Client.CoreV1().Services(k.Namespace)
+	for _, svc := range services {
+		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			item, err := client.Get(context.Background(), svc.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			item.Spec.Selector[colorLabel] = color
+			_, err = client.Update(context.Background(), item, metav1.UpdateOptions{})
+			return err
+		})
+		if retryErr != nil {
+			return fmt.Errorf("could not switch service/%s to %s: %v", svc.Name, color, retryErr)
+		}
+		fmt.Printf("service/%s now routes to %s\n", svc.Name, color)
+	}
+	return nil
+}
+
+// groupServices returns the Services selecting pods of an app/env group
+func (k *KubeAPI) groupServices(app, env string) ([]corev1.Service, error) {
+	list, err := k.Client.CoreV1().Services(k.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var services []corev1.Service
+	for _, item := range list.Items {
+		if item.Spec.Selector[groupLabel()] == fmt.Sprintf("%s-%s", app, env) {
+			services = append(services, item)
+		}
+	}
+	return services, nil
+}
+
+// colorDeployments returns the Deployments of an app/env group of a color
+func (k *KubeAPI) colorDeployments(app, env, color string) ([]appsv1.Deployment, error) {
+	deployments, err := k.groupDeployments(app, env)
+	if err != nil {
+		return nil, err
+	}
+	var colored []appsv1.Deployment
+	for _, d := range deployments {
+		if d.Spec.Template.ObjectMeta.Labels[colorLabel] == color {
+			colored = append(colored, d)
+		}
+	}
+	return colored, nil
+}
+
+// colorDeployment returns a copy of a Deployment of one color as the other
+// color, e.g. foo-web-blue => foo-web-green. A Deployment of the other color
+// named without a color suffix (e.g. foo-web labeled blue from before its
+// app used blue/green deploys) among existing is taken over instead of
+// creating foo-web-blue next to it, which would leave foo-web serving its
+// old tag alongside the new one
+func colorDeployment(d appsv1.Deployment, from, to string, existing []appsv1.Deployment) *appsv1.Deployment {
+	base := strings.TrimSuffix(d.Name, "-"+from)
+	name := base + "-" + to
+	for _, e := range existing {
+		if e.Name == base {
+			name = base
+		}
+	}
+	c := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: d.Namespace,
+			Labels:    withLabel(d.Labels, colorLabel, to),
+		},
+		Spec: *d.Spec.DeepCopy(),
+	}
+	c.Spec.Template.ObjectMeta.Labels = withLabel(d.Spec.Template.ObjectMeta.Labels, colorLabel, to)
+	if c.Spec.Selector == nil {
+		c.Spec.Selector = &metav1.LabelSelector{}
+	}
+	c.Spec.Selector.MatchLabels = withLabel(c.Spec.Selector.MatchLabels, colorLabel, to)
+	return c
+}
+
+// OtherColor returns the opposite of a blue/green color
+func OtherColor(color string) string {
+	if color == "blue" {
+		return "green"
+	}
+	return "blue"
+}
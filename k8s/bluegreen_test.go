@@ -0,0 +1,208 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func blueGreenService(name, color string) *corev1.Service {
+	selector := map[string]string{"group": "foo-production"}
+	if color != "" {
+		selector[colorLabel] = color
+	}
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+		Spec:       corev1.ServiceSpec{Selector: selector},
+	}
+}
+
+func blueGreenDeployment(name, color, tag string, ready bool) *appsv1.Deployment {
+	labels := map[string]string{"group": "foo-production", colorLabel: color}
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: labels},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "quay.io/foo:" + tag}}},
+			},
+		},
+	}
+	if ready {
+		d.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	}
+	return d
+}
+
+func TestActiveColor(t *testing.T) {
+	cases := []struct {
+		services []runtime.Object
+		exp      string
+		err      bool
+	}{
+		{services: []runtime.Object{blueGreenService("foo", "blue"), blueGreenService("foo-internal", "blue")}, exp: "blue"},
+		{services: []runtime.Object{blueGreenService("foo", "green")}, exp: "green"},
+		{services: nil, err: true},
+		{services: []runtime.Object{blueGreenService("foo", "")}, err: true},
+		{services: []runtime.Object{blueGreenService("foo", "blue"), blueGreenService("foo-internal", "green")}, err: true},
+	}
+	for _, test := range cases {
+		k := &KubeAPI{Client: fake.NewSimpleClientset(test.services...), Namespace: "test"}
+		active, err := k.ActiveColor("foo", "production")
+		if test.err {
+			if err == nil {
+				t.Errorf("expected error for services %v", test.services)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active != test.exp {
+			t.Errorf("expected active color %s got %s", test.exp, active)
+		}
+	}
+}
+
+func TestDeployBlueGreen(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		blueGreenService("foo", "blue"),
+		blueGreenDeployment("foo-web-blue", "blue", "v1", true),
+	)
+	// the fake clientset has no controllers so report created Deployments
+	// as rolled out
+	client.PrependReactor("create", "deployments", func(action ktesting.Action) (bool, runtime.Object, error) {
+		d := action.(ktesting.CreateAction).GetObject().(*appsv1.Deployment)
+		d.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+		return false, nil, nil
+	})
+	k := &KubeAPI{Client: client, Namespace: "test"}
+
+	inactive, changes, err := k.BlueGreenPlan("foo", "production", "v2", "foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inactive != "green" || len(changes) != 1 || changes[0].Name != "foo-web-green" {
+		t.Errorf("expected plan to deploy foo-web-green got %s %v", inactive, changes)
+	}
+
+	if err := k.DeployBlueGreen("foo", "production", "v2", "foo", "", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	green, err := client.AppsV1().Deployments("test").Get(context.Background(), "foo-web-green", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := green.Spec.Template.Spec.Containers[0].Image; image != "quay.io/foo:v2" {
+		t.Errorf("expected green to run quay.io/foo:v2 got %s", image)
+	}
+	if green.Spec.Selector.MatchLabels[colorLabel] != "green" {
+		t.Errorf("expected green deployment to select green pods got %v", green.Spec.Selector.MatchLabels)
+	}
+	if active, _ := k.ActiveColor("foo", "production"); active != "green" {
+		t.Errorf("expected services to switch to green got %s", active)
+	}
+	if tag, _ := k.BlueGreenTag("foo", "production", "foo", ""); tag != "v2" {
+		t.Errorf("expected active tag v2 got %s", tag)
+	}
+}
+
+func TestDeployBlueGreenUnsuffixed(t *testing.T) {
+	// foo-web ran blue before the app used blue/green deploys
+	client := fake.NewSimpleClientset(
+		blueGreenService("foo", "blue"),
+		blueGreenDeployment("foo-web", "blue", "v1", true),
+	)
+	client.PrependReactor("create", "deployments", func(action ktesting.Action) (bool, runtime.Object, error) {
+		d := action.(ktesting.CreateAction).GetObject().(*appsv1.Deployment)
+		d.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+		return false, nil, nil
+	})
+	k := &KubeAPI{Client: client, Namespace: "test"}
+
+	if err := k.DeployBlueGreen("foo", "production", "v2", "foo", "", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	_, changes, err := k.BlueGreenPlan("foo", "production", "v3", "foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Name != "foo-web" {
+		t.Errorf("expected plan to deploy foo-web got %v", changes)
+	}
+	if err := k.DeployBlueGreen("foo", "production", "v3", "foo", "", time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.AppsV1().Deployments("test").Get(context.Background(), "foo-web-blue", metav1.GetOptions{}); err == nil {
+		t.Error("expected foo-web to be taken over instead of creating foo-web-blue")
+	}
+	blue, err := k.colorDeployments("foo", "production", "blue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blue) != 1 || blue[0].Spec.Template.Spec.Containers[0].Image != "quay.io/foo:v3" {
+		t.Errorf("expected only foo-web running quay.io/foo:v3 to be blue got %v", blue)
+	}
+	if active, _ := k.ActiveColor("foo", "production"); active != "blue" {
+		t.Errorf("expected services to switch back to blue got %s", active)
+	}
+}
+
+func TestSwitchColor(t *testing.T) {
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			blueGreenService("foo", "green"),
+			blueGreenDeployment("foo-web-green", "green", "v2", true),
+			blueGreenDeployment("foo-web-blue", "blue", "v1", true),
+		),
+		Namespace: "test",
+	}
+	active, err := k.SwitchColor("foo", "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active != "blue" {
+		t.Errorf("expected to switch to blue got %s", active)
+	}
+	svc, err := k.Client.CoreV1().Services("test").Get(context.Background(), "foo", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Spec.Selector[colorLabel] != "blue" || svc.Spec.Selector["group"] != "foo-production" {
+		t.Errorf("expected service to select group foo-production and color blue got %v", svc.Spec.Selector)
+	}
+
+	// the inactive color must be ready to receive traffic
+	k = &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			blueGreenService("foo", "green"),
+			blueGreenDeployment("foo-web-green", "green", "v2", true),
+			blueGreenDeployment("foo-web-blue", "blue", "v1", false),
+		),
+		Namespace: "test",
+	}
+	if _, err := k.SwitchColor("foo", "production"); err == nil {
+		t.Error("expected error switching to an unavailable color")
+	}
+	if active, _ := k.ActiveColor("foo", "production"); active != "green" {
+		t.Errorf("expected traffic to stay on green got %s", active)
+	}
+
+	// and exist
+	k = &KubeAPI{
+		Client:    fake.NewSimpleClientset(blueGreenService("foo", "green"), blueGreenDeployment("foo-web-green", "green", "v2", true)),
+		Namespace: "test",
+	}
+	if _, err := k.SwitchColor("foo", "production"); err == nil {
+		t.Error("expected error switching to a color without deployments")
+	}
+}
@@ -0,0 +1,248 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// trackLabel distinguishes canary pods from the rest of a group
+	trackLabel = "track"
+	canary     = "canary"
+)
+
+// DeployCanary creates (or updates) a <name>-canary copy of every
+// Deployment in the app/env group running the given tag. The canary is
+// sized to receive roughly percent of the traffic of its Deployment
+func (k *KubeAPI) DeployCanary(app, env, tag, repo, container string, percent int) error {
+	if percent < 1 || percent > 99 {
+		return fmt.Errorf("canary percent must be between 1 and 99")
+	}
+	deployments, err := k.groupDeployments(app, env)
+	if err != nil {
+		return err
+	}
+	if len(deployments) == 0 {
+		return fmt.Errorf("no deployments found for %s-%s to canary", app, env)
+	}
+
+	for _, d := range deployments {
+		if err := checkCanarySelector(d, canaryDeployment(d, percent)); err != nil {
+			return err
+		}
+	}
+
+	client := k.Client.AppsV1().Deployments(k.Namespace)
+	for _, d := range deployments {
+		c := canaryDeployment(d, percent)
+		setImage("deployment", c.Name, &c.Spec.Template, tag, repo, container)
+
+		existing, err := client.Get(context.Background(), c.Name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			fmt.Printf("creating deployment/%s with %d replica(s)\n", c.Name, *c.Spec.Replicas)
+			if _, err := client.Create(context.Background(), c, metav1.CreateOptions{}); err != nil {
+				return fmt.Errorf("could not create canary: %v", err)
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("updating deployment/%s with %d replica(s)\n", c.Name, *c.Spec.Replicas)
+		existing.Spec.Replicas = c.Spec.Replicas
+		existing.Spec.Template = c.Spec.Template
+		if _, err := client.Update(context.Background(), existing, metav1.UpdateOptions{}); err != nil {
+			return fmt.Errorf("could not update canary: %v", err)
+		}
+	}
+	return nil
+}
+
+// WaitForCanary waits for the canaries of an app/env group to roll out and
+// then watches them for the hold period. An error is returned if a canary
+// does not roll out within timeout or any canary pod restarts or becomes
+// unready while being held
+func (k *KubeAPI) WaitForCanary(app, env string, timeout, hold time.Duration) error {
+	progress := map[string]string{}
+	err := wait.PollImmediate(rolloutInterval, timeout, func() (bool, error) {
+		canaries, err := k.groupCanaries(app, env)
+		if err != nil {
+			return false, err
+		}
+		done := true
+		for _, c := range canaries {
+			msg, ok, err := deploymentRolloutStatus(c)
+			if err != nil {
+				return false, err
+			}
+			if progress[c.Name] != msg {
+				fmt.Printf("  %s: %s\n", cyan("deployment/"+c.Name), msg)
+				progress[c.Name] = msg
+			}
+			done = done && ok
+		}
+		return done, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("canary of %s-%s did not roll out within %s", app, env, timeout)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("holding canary for %s...\n", hold)
+	deadline := time.Now().Add(hold)
+	for {
+		if err := k.checkCanaryPods(app, env); err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		time.Sleep(rolloutInterval)
+	}
+}
+
+// DeleteCanary removes the canaries of an app/env group
+func (k *KubeAPI) DeleteCanary(app, env string) error {
+	canaries, err := k.groupCanaries(app, env)
+	if err != nil {
+		return err
+	}
+	client := k.Client.AppsV1().Deployments(k.Namespace)
+	for _, c := range canaries {
+		fmt.Printf("deleting deployment/%s\n", c.Name)
+		if err := client.Delete(context.Background(), c.Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCanaryPods returns an error if any canary pod is unready or has restarted
+func (k *KubeAPI) checkCanaryPods(app, env string) error {
+	canaries, err := k.groupCanaries(app, env)
+	if err != nil {
+		return err
+	}
+	for _, c := range canaries {
+		selector, err := metav1.LabelSelectorAsSelector(c.Spec.Selector)
+		if err != nil {
+			return err
+		}
+		pods, err := k.Client.CoreV1().Pods(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return err
+		}
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			for _, cs := range pod.Status.ContainerStatuses {
+				if cs.RestartCount > 0 {
+					return fmt.Errorf("canary pod %s container %s restarted %d time(s)", pod.Name, cs.Name, cs.RestartCount)
+				}
+			}
+			if !podReady(pod) {
+				return fmt.Errorf("canary pod %s is not ready", pod.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// groupCanaries returns the canary Deployments of an app/env group
+func (k *KubeAPI) groupCanaries(app, env string) ([]appsv1.Deployment, error) {
+	selector := fmt.Sprintf("%s=%s-%s,%s=%s", groupLabel(), app, env, trackLabel, canary)
+	list, err := k.Client.AppsV1().Deployments(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// canaryDeployment returns a canary copy of a Deployment. Canary pods keep
+// the group labels (so Services send them traffic) and are told apart from
+// the Deployment's own pods by the track label
+func canaryDeployment(d appsv1.Deployment, percent int) *appsv1.Deployment {
+	var replicas int32 = 1
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	// size the canary so it serves percent of the combined replicas
+	n := (int(replicas)*percent + (100 - percent) - 1) / (100 - percent)
+	if n < 1 {
+		n = 1
+	}
+	canaryReplicas := int32(n)
+
+	c := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        d.Name + "-" + canary,
+			Namespace:   d.Namespace,
+			Labels:      withLabel(d.Labels, trackLabel, canary),
+			Annotations: map[string]string{},
+		},
+		Spec: *d.Spec.DeepCopy(),
+	}
+	c.Spec.Replicas = &canaryReplicas
+	c.Spec.Template.ObjectMeta.Labels = withLabel(d.Spec.Template.ObjectMeta.Labels, trackLabel, canary)
+	if c.Spec.Selector == nil {
+		c.Spec.Selector = &metav1.LabelSelector{}
+	}
+	c.Spec.Selector.MatchLabels = withLabel(c.Spec.Selector.MatchLabels, trackLabel, canary)
+	// e.g. track notin (canary) of the Deployment would exclude the canary's
+	// own pods
+	var expressions []metav1.LabelSelectorRequirement
+	for _, e := range c.Spec.Selector.MatchExpressions {
+		if e.Key != trackLabel {
+			expressions = append(expressions, e)
+		}
+	}
+	c.Spec.Selector.MatchExpressions = expressions
+
+	return c
+}
+
+// checkCanarySelector returns an error if the selector of a Deployment
+// also matches the pods of its canary. Deployments with overlapping
+// selectors fight over each other's pods so the Deployment must select a
+// label its canary does not have, e.g. track=stable
+func checkCanarySelector(d appsv1.Deployment, c *appsv1.Deployment) error {
+	selector, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector of deployment/%s: %s", d.Name, err)
+	}
+	if selector.Matches(labels.Set(c.Spec.Template.Labels)) {
+		return fmt.Errorf("deployment/%s selects the pods of its canary, add %s: stable to its selector and pod labels to deploy canaries", d.Name, trackLabel)
+	}
+	return nil
+}
+
+// withLabel returns a copy of labels with key set to value
+func withLabel(labels map[string]string, key, value string) map[string]string {
+	l := map[string]string{}
+	for k, v := range labels {
+		l[k] = v
+	}
+	l[key] = value
+	return l
+}
+
+// podReady reports whether a pod's Ready condition is true
+func podReady(pod corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCanaryDeployment(t *testing.T) {
+	cases := []struct {
+		replicas int32
+		percent  int
+		canaries int32
+	}{
+		{replicas: 9, percent: 10, canaries: 1},
+		{replicas: 3, percent: 50, canaries: 3},
+		{replicas: 10, percent: 25, canaries: 4},
+		{replicas: 1, percent: 1, canaries: 1},
+	}
+	for _, test := range cases {
+		replicas := test.replicas
+		d := appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-web", Labels: map[string]string{"group": "foo-stage"}},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"group": "foo-stage"}},
+			},
+		}
+		d.Spec.Template.ObjectMeta.Labels = map[string]string{"group": "foo-stage", "env": "stage"}
+
+		c := canaryDeployment(d, test.percent)
+		if c.Name != "foo-web-canary" {
+			t.Errorf("expected canary name foo-web-canary got %s", c.Name)
+		}
+		if *c.Spec.Replicas != test.canaries {
+			t.Errorf("expected %d canary replicas for %d%% of %d got %d", test.canaries, test.percent, test.replicas, *c.Spec.Replicas)
+		}
+		if c.Spec.Selector.MatchLabels[trackLabel] != canary || c.Spec.Template.Labels[trackLabel] != canary {
+			t.Errorf("expected canary selector and pods to be labeled %s=%s", trackLabel, canary)
+		}
+		if selector, _ := labels.Parse(groupSelector("foo", "stage")); selector.Matches(labels.Set(c.Labels)) {
+			t.Error("expected canary to be excluded from its group")
+		}
+		if _, ok := d.Spec.Template.Labels[trackLabel]; ok {
+			t.Error("expected original deployment labels to be unchanged")
+		}
+	}
+}
+
+func TestDeployCanarySelector(t *testing.T) {
+	deployment := func(selector *metav1.LabelSelector, podLabels map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-web", Namespace: "test", Labels: map[string]string{"group": "foo-stage"}},
+			Spec: appsv1.DeploymentSpec{
+				Selector: selector,
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "quay.io/foo:v1"}}},
+				},
+			},
+		}
+	}
+	cases := []struct {
+		deployment *appsv1.Deployment
+		err        bool
+	}{
+		// the canary's pods would also be selected by foo-web
+		{
+			deployment: deployment(&metav1.LabelSelector{MatchLabels: map[string]string{"group": "foo-stage"}}, map[string]string{"group": "foo-stage"}),
+			err:        true,
+		},
+		{
+			deployment: deployment(
+				&metav1.LabelSelector{MatchLabels: map[string]string{"group": "foo-stage", trackLabel: "stable"}},
+				map[string]string{"group": "foo-stage", trackLabel: "stable"},
+			),
+		},
+		{
+			deployment: deployment(
+				&metav1.LabelSelector{
+					MatchLabels:      map[string]string{"group": "foo-stage"},
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: trackLabel, Operator: metav1.LabelSelectorOpNotIn, Values: []string{canary}}},
+				},
+				map[string]string{"group": "foo-stage"},
+			),
+		},
+	}
+	for _, test := range cases {
+		k := &KubeAPI{Client: fake.NewSimpleClientset(test.deployment), Namespace: "test"}
+		err := k.DeployCanary("foo", "stage", "v2", "foo", "", 10)
+		c, getErr := k.Client.AppsV1().Deployments("test").Get(context.Background(), "foo-web-canary", metav1.GetOptions{})
+		if test.err {
+			if err == nil || getErr == nil {
+				t.Errorf("expected canary of selector %v to be refused", test.deployment.Spec.Selector)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if getErr != nil {
+			t.Fatal(getErr)
+		}
+		selector, err := metav1.LabelSelectorAsSelector(c.Spec.Selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !selector.Matches(labels.Set(c.Spec.Template.Labels)) {
+			t.Errorf("expected canary selector %v to select its pods %v", c.Spec.Selector, c.Spec.Template.Labels)
+		}
+	}
+}
@@ -3,31 +3,136 @@ package k8s
 import (
 	"fmt"
 
+	"github.com/deepthawtz/duncan/config"
 	"github.com/spf13/viper"
 
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 // KubeAPI performs all the Kubernetes API operations
 type KubeAPI struct {
 	Client    kubernetes.Interface
+	Cluster   string
 	Namespace string
+	// Config is used for requests the clientset cannot make, e.g. exec
+	Config *rest.Config
+	// Prefix, if set, starts every rollout progress line, e.g. to tell
+	// apart clusters deployed to in parallel
+	Prefix string
 }
 
-// NewClient returns a new KubeAPI client
+// Cluster is a kubeconfig context and the namespace apps are deployed
+// to in that cluster
+type Cluster struct {
+	Name      string
+	Namespace string
+}
+
+// Clusters returns the clusters listed as kubernetes_clusters in duncan.yml,
+// falling back to kubernetes_cluster and kubernetes_namespace. If names are
+// given only those clusters are returned
+//
+// e.g.,
+//
+//     kubernetes_namespace: pipeline
+//     kubernetes_clusters:
+//       - name: kube-us-east
+//       - name: kube-eu-west
+//         namespace: pipeline-eu
+func Clusters(names []string) ([]Cluster, error) {
+	var clusters []Cluster
+	if err := viper.UnmarshalKey("kubernetes_clusters", &clusters); err != nil {
+		return nil, fmt.Errorf("invalid kubernetes_clusters in duncan.yml: %s", err)
+	}
+	if len(clusters) == 0 {
+		cluster := viper.GetString("kubernetes_cluster")
+		if cluster == "" {
+			return nil, fmt.Errorf("must supply kubernetes_cluster in duncan.yml")
+		}
+		clusters = []Cluster{{Name: cluster}}
+	}
+	for i, c := range clusters {
+		if c.Name == "" {
+			return nil, fmt.Errorf("every kubernetes_clusters entry must have a name")
+		}
+		if c.Namespace == "" {
+			clusters[i].Namespace = viper.GetString("kubernetes_namespace")
+		}
+		if clusters[i].Namespace == "" {
+			return nil, fmt.Errorf("must supply kubernetes_namespace in duncan.yml")
+		}
+	}
+	if len(names) == 0 {
+		return clusters, nil
+	}
+
+	var selected []Cluster
+	for _, name := range names {
+		found := false
+		for _, c := range clusters {
+			if c.Name == name {
+				selected = append(selected, c)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("cluster %s is not configured in duncan.yml", name)
+		}
+	}
+	return selected, nil
+}
+
+// AppClusters returns the clusters an app/env is deployed to. An app/env
+// mapped to a cluster under apps in duncan.yml is only deployed there
+// unless names are given, otherwise Clusters(names) are used. The app/env
+// namespace in duncan.yml replaces the namespace of its clusters if set
+func AppClusters(app, env string, names []string) ([]Cluster, error) {
+	a := config.AppEnv(app, env)
+	if a.Cluster != "" && len(names) == 0 {
+		c := Cluster{Name: a.Cluster, Namespace: a.Namespace}
+		if c.Namespace == "" {
+			c.Namespace = viper.GetString("kubernetes_namespace")
+			if configured, err := Clusters([]string{a.Cluster}); err == nil {
+				c.Namespace = configured[0].Namespace
+			}
+		}
+		if c.Namespace == "" {
+			return nil, fmt.Errorf("must supply a namespace for %s %s or kubernetes_namespace in duncan.yml", app, env)
+		}
+		return []Cluster{c}, nil
+	}
+
+	clusters, err := Clusters(names)
+	if err != nil {
+		return nil, err
+	}
+	if a.Namespace != "" {
+		for i := range clusters {
+			clusters[i].Namespace = a.Namespace
+		}
+	}
+	return clusters, nil
+}
+
+// NewClient returns a new KubeAPI client of the only configured cluster
 func NewClient() (*KubeAPI, error) {
-	cluster := viper.GetString("kubernetes_cluster")
-	namespace := viper.GetString("kubernetes_namespace")
-	if cluster == "" {
-		return nil, fmt.Errorf("must supply kubernetes_cluster in duncan.yml")
+	clusters, err := Clusters(nil)
+	if err != nil {
+		return nil, err
 	}
-	if namespace == "" {
-		return nil, fmt.Errorf("must supply kubernetes_namespace in duncan.yml")
+	if len(clusters) > 1 {
+		return nil, fmt.Errorf("%d kubernetes_clusters are configured, a single cluster must be chosen", len(clusters))
 	}
+	return NewClusterClient(clusters[0])
+}
+
+// NewClusterClient returns a new KubeAPI client of a cluster
+func NewClusterClient(c Cluster) (*KubeAPI, error) {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	configOverrides := &clientcmd.ConfigOverrides{
-		CurrentContext: cluster,
+		CurrentContext: c.Name,
 	}
 	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
 	config, err := kubeConfig.ClientConfig()
@@ -41,6 +146,24 @@ func NewClient() (*KubeAPI, error) {
 
 	return &KubeAPI{
 		Client:    clientset,
-		Namespace: namespace,
+		Cluster:   c.Name,
+		Namespace: c.Namespace,
+		Config:    config,
 	}, nil
 }
+
+// groupLabel returns the label key naming the app-env group of a workload
+func groupLabel() string {
+	if l := viper.GetString("group_label"); l != "" {
+		return l
+	}
+	return "group"
+}
+
+// envLabel returns the label key naming the environment of a workload
+func envLabel() string {
+	if l := viper.GetString("env_label"); l != "" {
+		return l
+	}
+	return "env"
+}
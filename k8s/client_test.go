@@ -0,0 +1,157 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClusters(t *testing.T) {
+	viper.Set("kubernetes_cluster", "kube-legacy")
+	viper.Set("kubernetes_namespace", "pipeline")
+	defer viper.Set("kubernetes_cluster", "")
+	defer viper.Set("kubernetes_namespace", "")
+
+	clusters, err := Clusters(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || clusters[0] != (Cluster{Name: "kube-legacy", Namespace: "pipeline"}) {
+		t.Errorf("expected kubernetes_cluster to be used when no clusters are listed got %v", clusters)
+	}
+
+	viper.Set("kubernetes_clusters", []map[string]interface{}{
+		{"name": "kube-us-east"},
+		{"name": "kube-eu-west", "namespace": "pipeline-eu"},
+	})
+	defer viper.Set("kubernetes_clusters", nil)
+
+	clusters, err = Clusters(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []Cluster{{Name: "kube-us-east", Namespace: "pipeline"}, {Name: "kube-eu-west", Namespace: "pipeline-eu"}}
+	if len(clusters) != len(exp) {
+		t.Fatalf("expected %d clusters got %d", len(exp), len(clusters))
+	}
+	for i, c := range exp {
+		if clusters[i] != c {
+			t.Errorf("expected %v got %v", c, clusters[i])
+		}
+	}
+
+	clusters, err = Clusters([]string{"kube-eu-west"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 1 || clusters[0].Name != "kube-eu-west" {
+		t.Errorf("expected only kube-eu-west got %v", clusters)
+	}
+
+	if _, err := Clusters([]string{"kube-ap-south"}); err == nil {
+		t.Error("expected error choosing a cluster that is not configured")
+	}
+}
+
+func TestAppClusters(t *testing.T) {
+	viper.Set("kubernetes_namespace", "pipeline")
+	viper.Set("kubernetes_clusters", []map[string]interface{}{
+		{"name": "kube-us-east"},
+		{"name": "kube-eu-west", "namespace": "pipeline-eu"},
+	})
+	viper.Set("apps", map[string]interface{}{
+		"dogfood": map[string]interface{}{
+			"namespace": "dogfood",
+			"envs": map[string]interface{}{
+				"production": map[string]interface{}{"cluster": "kube-eu-west"},
+			},
+		},
+		"skulls": map[string]interface{}{
+			"envs": map[string]interface{}{
+				"production": map[string]interface{}{"cluster": "kube-eu-west"},
+			},
+		},
+	})
+	defer viper.Set("kubernetes_namespace", "")
+	defer viper.Set("kubernetes_clusters", nil)
+	defer viper.Set("apps", nil)
+
+	cases := []struct {
+		app, env string
+		names    []string
+		exp      []Cluster
+	}{
+		{app: "dogfood", env: "stage", exp: []Cluster{{"kube-us-east", "dogfood"}, {"kube-eu-west", "dogfood"}}},
+		{app: "dogfood", env: "production", exp: []Cluster{{"kube-eu-west", "dogfood"}}},
+		{app: "dogfood", env: "production", names: []string{"kube-us-east"}, exp: []Cluster{{"kube-us-east", "dogfood"}}},
+		{app: "skulls", env: "production", exp: []Cluster{{"kube-eu-west", "pipeline-eu"}}},
+		{app: "beefcake", env: "stage", exp: []Cluster{{"kube-us-east", "pipeline"}, {"kube-eu-west", "pipeline-eu"}}},
+	}
+	for _, test := range cases {
+		clusters, err := AppClusters(test.app, test.env, test.names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(clusters, test.exp) {
+			t.Errorf("expected %s %s %v to be deployed to %v got %v", test.app, test.env, test.names, test.exp, clusters)
+		}
+	}
+}
+
+func TestAppClustersEnvNamespaces(t *testing.T) {
+	viper.Set("kubernetes_clusters", []map[string]interface{}{{"name": "kube-us-east", "namespace": "pipeline"}})
+	viper.Set("apps", map[string]interface{}{
+		"foo": map[string]interface{}{
+			"envs": map[string]interface{}{
+				"stage":      map[string]interface{}{"namespace": "foo-stage"},
+				"production": map[string]interface{}{"namespace": "foo-production"},
+			},
+		},
+	})
+	defer viper.Set("kubernetes_clusters", nil)
+	defer viper.Set("apps", nil)
+
+	deployment := func(namespace, env, tag string) *appsv1.Deployment {
+		labels := map[string]string{"group": "foo-" + env}
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-web", Namespace: namespace, Labels: labels},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "quay.io/foo:" + tag}}},
+				},
+			},
+		}
+	}
+	client := fake.NewSimpleClientset(deployment("foo-stage", "stage", "v2"), deployment("foo-production", "production", "v1"))
+
+	// promoting reads the tag of the source env with a client of its own
+	// namespace
+	for env, exp := range map[string]string{"stage": "v2", "production": "v1"} {
+		clusters, err := AppClusters("foo", env, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(clusters) != 1 || clusters[0].Namespace != "foo-"+env {
+			t.Fatalf("expected foo %s to run in namespace foo-%s got %v", env, env, clusters)
+		}
+		k := &KubeAPI{Client: client, Cluster: clusters[0].Name, Namespace: clusters[0].Namespace}
+		tag, err := k.CurrentTag("foo", env, "foo", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tag != exp {
+			t.Errorf("expected foo %s to run %s got %s", env, exp, tag)
+		}
+	}
+
+	production := &KubeAPI{Client: client, Namespace: "foo-production"}
+	if _, err := production.CurrentTag("foo", "stage", "foo", ""); err == nil {
+		t.Error("expected no stage tag in the production namespace")
+	}
+}
@@ -3,7 +3,9 @@ package k8s
 import (
 	"context"
 	"fmt"
-	"strings"
+	"path"
+
+	"github.com/deepthawtz/duncan/docker"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -11,77 +13,177 @@ import (
 	"k8s.io/client-go/util/retry"
 )
 
+// ContainerChange describes a container image that a deploy will update
+type ContainerChange struct {
+	Kind      string
+	Name      string
+	Container string
+	From      string
+	To        string
+}
+
 // CurrentTag fetches the currently deployed docker image tag for
-// given app and env if it exists. First checks Kubernetes Deployment API
-// and then Stateful Sets API
-func (k *KubeAPI) CurrentTag(app, env, repo string) (string, error) {
-	deploymentsClient := k.Client.AppsV1().Deployments(k.Namespace)
-	deploymentList, err := deploymentsClient.List(context.Background(), metav1.ListOptions{})
+// given app and env if it exists. Deployments are checked first, then
+// StatefulSets, DaemonSets, CronJobs and finally Jobs. Only containers
+// running the docker repo (or the named container if given) are considered
+func (k *KubeAPI) CurrentTag(app, env, repo, container string) (string, error) {
+	workloads, err := k.groupWorkloads(app, env)
 	if err != nil {
 		return "", err
 	}
-	for _, item := range deploymentList.Items {
-		tag := findTag(app, env, item.Spec.Template)
+	for _, w := range workloads {
+		tag := findTag(repo, container, w.Template)
 		if tag != "" {
 			return tag, nil
 		}
 	}
 
-	ssClient := k.Client.AppsV1().StatefulSets(k.Namespace)
-	ssList, err := ssClient.List(context.Background(), metav1.ListOptions{})
+	return "", fmt.Errorf("could not find tag of %s for %s-%s", docker.ImageName(repo), app, env)
+}
+
+// DeployPlan returns every container image that deploying tag would change
+// for a given app/env without changing anything. Jobs cannot be changed
+// once created so are never part of a deploy
+func (k *KubeAPI) DeployPlan(app, env, tag, repo, container string) ([]ContainerChange, error) {
+	var changes []ContainerChange
+
+	workloads, err := k.groupWorkloads(app, env)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	for _, item := range ssList.Items {
-		tag := findTag(app, env, item.Spec.Template)
-		if tag != "" {
-			return tag, nil
+	for _, w := range workloads {
+		if w.Kind == "job" {
+			continue
 		}
+		changes = append(changes, setImage(w.Kind, w.Name, &w.Template, tag, repo, container)...)
 	}
 
-	return "", fmt.Errorf("could not find tag for %s-%s", app, env)
+	if len(changes) == 0 {
+		if container != "" {
+			return nil, fmt.Errorf("no container named %s found for %s-%s", container, app, env)
+		}
+		return nil, fmt.Errorf("no containers running %s found for %s-%s", docker.ImageName(repo), app, env)
+	}
+	return changes, nil
 }
 
-// Deploy updates docker image tag for a given k8s deployment
-func (k *KubeAPI) Deploy(app, env, tag, repo string) error {
-	if err := k.updateDeployment(app, env, tag, repo); err != nil {
+// Deploy updates docker image tag of matching containers for a given
+// app/env's Deployments, StatefulSets, DaemonSets and CronJobs
+func (k *KubeAPI) Deploy(app, env, tag, repo, container string) error {
+	if err := k.updateDeployment(app, env, tag, repo, container); err != nil {
+		return err
+	}
+	if err := k.updateStatefulSet(app, env, tag, repo, container); err != nil {
+		return err
+	}
+	if err := k.updateDaemonSet(app, env, tag, repo, container); err != nil {
 		return err
 	}
 
-	return k.updateStatefulSet(app, env, tag, repo)
+	return k.updateCronJob(app, env, tag, repo, container)
 }
 
-func findTag(app, env string, template corev1.PodTemplateSpec) string {
-	group := template.ObjectMeta.Labels["group"]
-	containers := template.Spec.Containers
+// findTag returns the image tag of the first container matching
+// the docker repo or container name
+func findTag(repo, container string, template corev1.PodTemplateSpec) string {
+	var containers []corev1.Container
+	containers = append(containers, template.Spec.Containers...)
+	containers = append(containers, template.Spec.InitContainers...)
+	for _, c := range containers {
+		if !matchesContainer(c, repo, container) {
+			continue
+		}
+		img, err := docker.ParseImage(c.Image)
+		if err != nil {
+			continue
+		}
+		return img.Version()
+	}
+
+	return ""
+}
 
-	if group == fmt.Sprintf("%s-%s", app, env) {
-		for _, container := range containers {
-			parts := strings.Split(container.Image, ":")
-			if len(parts) != 2 {
+// matchesContainer reports whether a deploy should update a container.
+// If a container name is given only that container matches, otherwise
+// containers running the docker repo match
+func matchesContainer(c corev1.Container, repo, container string) bool {
+	if container != "" {
+		return c.Name == container
+	}
+	img, err := docker.ParseImage(c.Image)
+	if err != nil {
+		return false
+	}
+	name := docker.ImageName(repo)
+	if name == repo {
+		// no docker_repo_prefix to compare against so match on repo alone
+		return path.Base(img.Name) == repo
+	}
+	return img.Name == name
+}
+
+// setImage sets the image version of every matching container and init
+// container in a pod template and returns what changed. tag may also be
+// a digest or a tag pinned to a digest (tag@digest)
+func setImage(kind, name string, template *corev1.PodTemplateSpec, tag, repo, container string) []ContainerChange {
+	var changes []ContainerChange
+	update := func(containers []corev1.Container) {
+		for i, c := range containers {
+			if !matchesContainer(c, repo, container) {
+				continue
+			}
+			img, err := docker.ParseImage(c.Image)
+			if err != nil {
 				continue
 			}
-			return parts[1]
+			image := img.WithVersion(tag).String()
+			changes = append(changes, ContainerChange{
+				Kind:      kind,
+				Name:      name,
+				Container: c.Name,
+				From:      c.Image,
+				To:        image,
+			})
+			containers[i].Image = image
 		}
 	}
+	update(template.Spec.InitContainers)
+	update(template.Spec.Containers)
 
-	return ""
+	return changes
+}
+
+// groupSelector returns the label selector of the workloads of an app/env
+// group. Canaries are managed separately so are not part of their group
+func groupSelector(app, env string) string {
+	return fmt.Sprintf("%s=%s-%s,%s!=%s", groupLabel(), app, env, trackLabel, canary)
+}
+
+// groupDeployments returns all Deployments belonging to an app/env group
+func (k *KubeAPI) groupDeployments(app, env string) ([]appsv1.Deployment, error) {
+	list, err := k.Client.AppsV1().Deployments(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: groupSelector(app, env)})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// groupStatefulSets returns all StatefulSets belonging to an app/env group
+func (k *KubeAPI) groupStatefulSets(app, env string) ([]appsv1.StatefulSet, error) {
+	list, err := k.Client.AppsV1().StatefulSets(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: groupSelector(app, env)})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
 }
 
-func (k *KubeAPI) updateDeployment(app, env, tag, repo string) error {
+func (k *KubeAPI) updateDeployment(app, env, tag, repo, container string) error {
 	deploymentsClient := k.Client.AppsV1().Deployments(k.Namespace)
 
-	list, err := deploymentsClient.List(context.Background(), metav1.ListOptions{})
+	toUpdate, err := k.groupDeployments(app, env)
 	if err != nil {
 		return err
 	}
-	var toUpdate []appsv1.Deployment
-	for _, item := range list.Items {
-		group := item.Spec.Template.ObjectMeta.Labels["group"]
-		if group == fmt.Sprintf("%s-%s", app, env) {
-			toUpdate = append(toUpdate, item)
-		}
-	}
 
 	for _, deployment := range toUpdate {
 		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -89,9 +191,9 @@ func (k *KubeAPI) updateDeployment(app, env, tag, repo string) error {
 			if err != nil {
 				return err
 			}
-			image := item.Spec.Template.Spec.Containers[0].Image
-			parts := strings.Split(image, ":")
-			item.Spec.Template.Spec.Containers[0].Image = fmt.Sprintf("%s:%s", parts[0], tag)
+			if len(setImage("deployment", item.Name, &item.Spec.Template, tag, repo, container)) == 0 {
+				return nil
+			}
 			_, err = deploymentsClient.Update(context.Background(), item, metav1.UpdateOptions{})
 			return err
 		})
@@ -103,20 +205,13 @@ func (k *KubeAPI) updateDeployment(app, env, tag, repo string) error {
 	return nil
 }
 
-func (k *KubeAPI) updateStatefulSet(app, env, tag, repo string) error {
+func (k *KubeAPI) updateStatefulSet(app, env, tag, repo, container string) error {
 	ssClient := k.Client.AppsV1().StatefulSets(k.Namespace)
 
-	ssList, err := ssClient.List(context.Background(), metav1.ListOptions{})
+	toUpdate, err := k.groupStatefulSets(app, env)
 	if err != nil {
 		return err
 	}
-	var toUpdate []appsv1.StatefulSet
-	for _, item := range ssList.Items {
-		group := item.Spec.Template.ObjectMeta.Labels["group"]
-		if group == fmt.Sprintf("%s-%s", app, env) {
-			toUpdate = append(toUpdate, item)
-		}
-	}
 
 	for _, deployment := range toUpdate {
 		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
@@ -124,9 +219,9 @@ func (k *KubeAPI) updateStatefulSet(app, env, tag, repo string) error {
 			if err != nil {
 				return err
 			}
-			image := item.Spec.Template.Spec.Containers[0].Image
-			parts := strings.Split(image, ":")
-			item.Spec.Template.Spec.Containers[0].Image = fmt.Sprintf("%s:%s", parts[0], tag)
+			if len(setImage("statefulset", item.Name, &item.Spec.Template, tag, repo, container)) == 0 {
+				return nil
+			}
 			_, err = ssClient.Update(context.Background(), item, metav1.UpdateOptions{})
 			return err
 		})
@@ -0,0 +1,148 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	"github.com/spf13/viper"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSetImage(t *testing.T) {
+	viper.Set("docker_repo_prefix", "quay.io/myorg")
+	defer viper.Set("docker_repo_prefix", "")
+	template := func() *corev1.PodTemplateSpec {
+		return &corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{
+					{Name: "migrate", Image: "quay.io/myorg/foo:1.0.0"},
+				},
+				Containers: []corev1.Container{
+					{Name: "proxy", Image: "quay.io/myorg/envoy:1.14"},
+					{Name: "web", Image: "quay.io/myorg/foo:1.0.0"},
+				},
+			},
+		}
+	}
+	cases := []struct {
+		container string
+		changed   []string
+	}{
+		{container: "", changed: []string{"migrate", "web"}},
+		{container: "web", changed: []string{"web"}},
+		{container: "proxy", changed: []string{"proxy"}},
+		{container: "nope", changed: []string{}},
+	}
+	for _, test := range cases {
+		tmpl := template()
+		changes := setImage("deployment", "foo-web", tmpl, "2.0.0", "foo", test.container)
+		if len(changes) != len(test.changed) {
+			t.Fatalf("expected %d changes got %d: %+v", len(test.changed), len(changes), changes)
+		}
+		for i, c := range changes {
+			if c.Container != test.changed[i] {
+				t.Errorf("expected container %s to change got %s", test.changed[i], c.Container)
+			}
+		}
+	}
+
+	tmpl := template()
+	setImage("deployment", "foo-web", tmpl, "2.0.0", "foo", "")
+	if tag := findTag("foo", "", *tmpl); tag != "2.0.0" {
+		t.Errorf("expected tag 2.0.0 got %s", tag)
+	}
+	if img := tmpl.Spec.Containers[0].Image; img != "quay.io/myorg/envoy:1.14" {
+		t.Errorf("expected sidecar image to be unchanged got %s", img)
+	}
+}
+
+func TestGroupDeployments(t *testing.T) {
+	viper.Set("group_label", "app.example.com/group")
+	defer viper.Set("group_label", "")
+	deployment := func(name string, labels map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: labels}}
+	}
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			deployment("foo-web", map[string]string{"app.example.com/group": "foo-stage"}),
+			deployment("foo-web-canary", map[string]string{"app.example.com/group": "foo-stage", trackLabel: canary}),
+			deployment("foo-worker", map[string]string{"app.example.com/group": "foo-production"}),
+			deployment("bar-web", map[string]string{"group": "foo-stage"}),
+		),
+		Namespace: "test",
+	}
+
+	deployments, err := k.groupDeployments("foo", "stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deployments) != 1 || deployments[0].Name != "foo-web" {
+		t.Errorf("expected only foo-web in group foo-stage got %v", deployments)
+	}
+	canaries, err := k.groupCanaries("foo", "stage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(canaries) != 1 || canaries[0].Name != "foo-web-canary" {
+		t.Errorf("expected only foo-web-canary as canary of foo-stage got %v", canaries)
+	}
+}
+
+func TestDeployPlanWorkloads(t *testing.T) {
+	viper.Set("docker_repo_prefix", "quay.io/myorg")
+	defer viper.Set("docker_repo_prefix", "")
+	labels := map[string]string{"group": "foo-stage"}
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "foo", Image: "quay.io/myorg/foo:1.0.0"}},
+		},
+	}
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "test", Labels: labels}
+	}
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			&appsv1.Deployment{ObjectMeta: meta("foo-web"), Spec: appsv1.DeploymentSpec{Template: template}},
+			&appsv1.Deployment{ObjectMeta: meta("foo-worker"), Spec: appsv1.DeploymentSpec{Template: template}},
+			&appsv1.DaemonSet{ObjectMeta: meta("foo-agent"), Spec: appsv1.DaemonSetSpec{Template: template}},
+			&batchv1beta1.CronJob{ObjectMeta: meta("foo-report"), Spec: batchv1beta1.CronJobSpec{
+				JobTemplate: batchv1beta1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: template}},
+			}},
+			&batchv1.Job{ObjectMeta: meta("foo-report-1593626793"), Spec: batchv1.JobSpec{Template: template}},
+		),
+		Namespace: "test",
+	}
+
+	plan, err := k.DeployPlan("foo", "stage", "2.0.0", "foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary := KindSummary(plan); summary != "2 deployments, 1 daemonset, 1 cronjob" {
+		t.Errorf("expected jobs to be left out of the deploy plan got %s", summary)
+	}
+
+	if err := k.Deploy("foo", "stage", "2.0.0", "foo", ""); err != nil {
+		t.Fatal(err)
+	}
+	tag, err := k.CurrentTag("foo", "stage", "foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag != "2.0.0" {
+		t.Errorf("expected current tag 2.0.0 got %s", tag)
+	}
+	cj, err := k.Client.BatchV1beta1().CronJobs("test").Get(context.Background(), "foo-report", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if image := cj.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image; image != "quay.io/myorg/foo:2.0.0" {
+		t.Errorf("expected cronjob to be deployed got %s", image)
+	}
+}
@@ -0,0 +1,113 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// ExecOptions configures a command run in a pod container
+type ExecOptions struct {
+	Pod       string
+	Container string
+	Command   []string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer
+	// TTY allocates a terminal of Width x Height, Stderr is then merged
+	// into Stdout
+	TTY           bool
+	Width, Height uint16
+}
+
+// ExecPod returns a ready pod of an app/env group to exec into. If pod is
+// given it must belong to the group, otherwise the first ready pod (canaries
+// excluded) is chosen
+func (k *KubeAPI) ExecPod(app, env, pod string) (*corev1.Pod, error) {
+	list, err := k.Client.CoreV1().Pods(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: groupSelector(app, env)})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	for i, p := range list.Items {
+		if pod != "" {
+			if p.Name == pod {
+				return &list.Items[i], nil
+			}
+			continue
+		}
+		if p.DeletionTimestamp == nil && podReady(p) {
+			return &list.Items[i], nil
+		}
+	}
+	if pod != "" {
+		return nil, fmt.Errorf("pod %s does not belong to %s-%s", pod, app, env)
+	}
+	return nil, fmt.Errorf("no ready pods found for %s-%s", app, env)
+}
+
+// ExecContainer returns the container of a pod to exec into by default, the
+// one running repo or else the first
+func ExecContainer(pod *corev1.Pod, repo string) string {
+	if i := containerIndex(pod.Spec.Containers, repo, ""); i >= 0 {
+		return pod.Spec.Containers[i].Name
+	}
+	return pod.Spec.Containers[0].Name
+}
+
+// Exec runs a command in a pod container, streaming its input and output
+// until it exits
+func (k *KubeAPI) Exec(opts ExecOptions) error {
+	if k.Config == nil {
+		return fmt.Errorf("exec is not supported by this client")
+	}
+	req := k.Client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(opts.Pod).
+		Namespace(k.Namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: opts.Container,
+			Command:   opts.Command,
+			Stdin:     opts.Stdin != nil,
+			Stdout:    opts.Stdout != nil,
+			Stderr:    opts.Stderr != nil && !opts.TTY,
+			TTY:       opts.TTY,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(k.Config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	streamOpts := remotecommand.StreamOptions{
+		Stdin:  opts.Stdin,
+		Stdout: opts.Stdout,
+		Stderr: opts.Stderr,
+		Tty:    opts.TTY,
+	}
+	if opts.TTY {
+		streamOpts.Stderr = nil
+		if opts.Width > 0 && opts.Height > 0 {
+			streamOpts.TerminalSizeQueue = &fixedSize{size: &remotecommand.TerminalSize{Width: opts.Width, Height: opts.Height}}
+		}
+	}
+	return executor.Stream(streamOpts)
+}
+
+// fixedSize is a TerminalSizeQueue reporting a single terminal size
+type fixedSize struct {
+	size *remotecommand.TerminalSize
+}
+
+// Next returns the terminal size once, nil then stops resizing
+func (f *fixedSize) Next() *remotecommand.TerminalSize {
+	size := f.size
+	f.size = nil
+	return size
+}
@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestExecPod(t *testing.T) {
+	pod := func(name string, ready bool, labels map[string]string) *corev1.Pod {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: labels},
+			Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+		}
+	}
+	group := map[string]string{"group": "foo-production"}
+	terminating := pod("foo-web-1", true, group)
+	now := metav1.NewTime(time.Now())
+	terminating.DeletionTimestamp = &now
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			terminating,
+			pod("foo-web-2", false, group),
+			pod("foo-web-3", true, group),
+			pod("foo-web-canary", true, map[string]string{"group": "foo-production", "track": "canary"}),
+			pod("bar-web-1", true, map[string]string{"group": "bar-production"}),
+		),
+		Namespace: "test",
+	}
+
+	cases := []struct {
+		pod string
+		exp string
+		err bool
+	}{
+		{exp: "foo-web-3"},
+		{pod: "foo-web-2", exp: "foo-web-2"},
+		{pod: "bar-web-1", err: true},
+	}
+	for _, test := range cases {
+		p, err := k.ExecPod("foo", "production", test.pod)
+		if test.err {
+			if err == nil {
+				t.Errorf("expected error for pod %s", test.pod)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Name != test.exp {
+			t.Errorf("expected pod %s got %s", test.exp, p.Name)
+		}
+	}
+}
+
+func TestExecContainer(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{
+		{Name: "proxy", Image: "quay.io/proxy:v2"},
+		{Name: "web", Image: "quay.io/foo:v1"},
+	}}}
+	if c := ExecContainer(pod, "foo"); c != "web" {
+		t.Errorf("expected container running foo got %s", c)
+	}
+	if c := ExecContainer(pod, "bar"); c != "proxy" {
+		t.Errorf("expected first container without one running bar got %s", c)
+	}
+}
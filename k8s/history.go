@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// revisionAnnotation is set by the Deployment controller on each ReplicaSet
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// TagHistory returns the docker image tags previously deployed for an
+// app/env, most recent first, based on the ReplicaSet revisions kept by
+// Kubernetes for the group's Deployments. The first tag is the one
+// currently deployed.
+func (k *KubeAPI) TagHistory(app, env, repo, container string) ([]string, error) {
+	deployments, err := k.groupDeployments(app, env)
+	if err != nil {
+		return nil, err
+	}
+	rsList, err := k.Client.AppsV1().ReplicaSets(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: groupSelector(app, env)})
+	if err != nil {
+		return nil, err
+	}
+
+	// every Deployment in a group is deployed together so use whichever
+	// one has the longest revision history
+	var history []string
+	for _, d := range deployments {
+		tags := replicaSetTags(repo, container, d, rsList.Items)
+		if len(tags) > len(history) {
+			history = tags
+		}
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("no deploy history found for %s-%s", app, env)
+	}
+
+	return history, nil
+}
+
+// replicaSetTags returns the distinct tags of the ReplicaSets owned by a
+// Deployment ordered by revision, most recent first
+func replicaSetTags(repo, container string, d appsv1.Deployment, replicaSets []appsv1.ReplicaSet) []string {
+	type revision struct {
+		number int64
+		tag    string
+	}
+	var revisions []revision
+	for _, rs := range replicaSets {
+		if !metav1.IsControlledBy(&rs, &d) {
+			continue
+		}
+		n, err := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if err != nil {
+			continue
+		}
+		if tag := findTag(repo, container, rs.Spec.Template); tag != "" {
+			revisions = append(revisions, revision{number: n, tag: tag})
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].number > revisions[j].number
+	})
+
+	var tags []string
+	seen := map[string]bool{}
+	for _, r := range revisions {
+		if !seen[r.tag] {
+			seen[r.tag] = true
+			tags = append(tags, r.tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,65 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTagHistory(t *testing.T) {
+	labels := map[string]string{"group": "foo-production"}
+	deployment := func(name string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", UID: types.UID(name), Labels: labels},
+		}
+	}
+	controller := true
+	replicaSet := func(owner, revision, tag string, labels map[string]string) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            owner + "-" + revision,
+				Namespace:       "test",
+				Labels:          labels,
+				Annotations:     map[string]string{revisionAnnotation: revision},
+				OwnerReferences: []metav1.OwnerReference{{Kind: "Deployment", Name: owner, UID: types.UID(owner), Controller: &controller}},
+			},
+			Spec: appsv1.ReplicaSetSpec{
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "quay.io/foo:" + tag}}}},
+			},
+		}
+	}
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			deployment("foo-web"),
+			deployment("foo-worker"),
+			replicaSet("foo-web", "2", "v2", labels),
+			replicaSet("foo-web", "10", "v4", labels),
+			replicaSet("foo-web", "3", "v3", labels),
+			// redeploying v2 reuses its ReplicaSet under a new revision
+			replicaSet("foo-web", "9", "v2", labels),
+			replicaSet("foo-web", "1", "v1", labels),
+			// worker only kept its latest revision
+			replicaSet("foo-worker", "4", "v4", labels),
+			// belongs to another group
+			replicaSet("foo-web", "11", "v5", map[string]string{"group": "foo-stage"}),
+		),
+		Namespace: "test",
+	}
+
+	tags, err := k.TagHistory("foo", "production", "foo", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := []string{"v4", "v2", "v3", "v1"}; !reflect.DeepEqual(tags, exp) {
+		t.Errorf("expected %v got %v", exp, tags)
+	}
+
+	if _, err := k.TagHistory("bar", "production", "bar", ""); err == nil {
+		t.Error("expected error when no history exists")
+	}
+}
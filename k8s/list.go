@@ -4,14 +4,19 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/deepthawtz/duncan/docker"
 	"github.com/fatih/color"
 	"github.com/olekukonko/tablewriter"
 
-	apiv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
 )
 
 var (
@@ -19,96 +24,312 @@ var (
 	cyan   = color.New(color.FgCyan, color.Bold).SprintFunc()
 	white  = color.New(color.FgWhite, color.Bold).SprintFunc()
 	green  = color.New(color.FgGreen, color.Bold).SprintFunc()
+	red    = color.New(color.FgRed, color.Bold).SprintFunc()
 )
 
 type deploymentGroups map[string][][]string
 
-// List displays k8s pods matching given app/env
-func (k *KubeAPI) List(app, env string) error {
+// List displays k8s workloads matching given app/env along with the
+// health of their pods. wide adds workload names, images, updated
+// replicas and rollout details
+func (k *KubeAPI) List(app, env string, wide bool) error {
 	if env == "" {
 		env = "stage|production"
 	}
-	deploymentsClient := k.Client.AppsV1().Deployments(k.Namespace)
-	ssClient := k.Client.AppsV1().StatefulSets(k.Namespace)
-
-	deploymentList, err := deploymentsClient.List(context.Background(), metav1.ListOptions{})
+	selector := listSelector(app, env)
+	workloads, err := k.listWorkloads(selector)
 	if err != nil {
 		return err
 	}
-
-	statefulSetList, err := ssClient.List(context.Background(), metav1.ListOptions{})
+	if err := k.setDeployed(selector, workloads); err != nil {
+		return err
+	}
+	restarts, err := k.restartCounts(selector, workloads)
 	if err != nil {
 		return err
 	}
 
-	groups := deploymentGroups{}
-	groups = collectDeploymentGroups(deploymentList, app, env, groups)
-	groups = collectStatefulSetGroups(statefulSetList, app, env, groups)
+	groups := collectGroups(workloads, restarts, app, env, wide)
 
-	for k, v := range groups {
-		fmt.Println(green(k))
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	header := []string{"ID", "Kind", "Tag", "Ready", "Restarts", "Age", "CPU Req/Limit", "Mem Req/Limit", "Status"}
+	if wide {
+		header = []string{"ID", "Kind", "Name", "Image", "Ready", "Updated", "Restarts", "Age", "CPU Req/Limit", "Mem Req/Limit", "Status"}
+	}
+	for _, name := range names {
+		fmt.Println(green(name))
 		table := tablewriter.NewWriter(os.Stdout)
-		table.SetHeader([]string{"ID", "Tag", "Instances", "CPU", "Mem"})
-		table.AppendBulk(v)
+		table.SetHeader(header)
+		table.AppendBulk(groups[name])
 		table.Render()
 	}
 
 	return nil
 }
 
-func collectDeploymentGroups(deploymentList *apiv1.DeploymentList, app string, env string, groups deploymentGroups) deploymentGroups {
-	for _, item := range deploymentList.Items {
-		group := item.Spec.Template.ObjectMeta.Labels["group"]
-		groupEnv := item.Spec.Template.ObjectMeta.Labels["env"]
-		replicas := item.Status.Replicas
-		for _, container := range item.Spec.Template.Spec.Containers {
-			groups = addContainerToGroup(groups, app, env, group, groupEnv, replicas, container)
+// setDeployed sets when the pod template of each workload last changed,
+// i.e. when its newest ReplicaSet or ControllerRevision was created.
+// Revisions carry their pod template labels so match the same selector
+func (k *KubeAPI) setDeployed(selector string, workloads []workload) error {
+	opts := metav1.ListOptions{LabelSelector: selector}
+	newest := map[types.UID]time.Time{}
+	update := func(owner *metav1.OwnerReference, created metav1.Time) {
+		if owner != nil && created.Time.After(newest[owner.UID]) {
+			newest[owner.UID] = created.Time
 		}
 	}
 
-	return groups
+	replicaSets, err := k.Client.AppsV1().ReplicaSets(k.Namespace).List(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	for i := range replicaSets.Items {
+		rs := &replicaSets.Items[i]
+		update(metav1.GetControllerOf(rs), rs.CreationTimestamp)
+	}
+	revisions, err := k.Client.AppsV1().ControllerRevisions(k.Namespace).List(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	for i := range revisions.Items {
+		cr := &revisions.Items[i]
+		update(metav1.GetControllerOf(cr), cr.CreationTimestamp)
+	}
+
+	for i, w := range workloads {
+		if !w.Deployed.IsZero() {
+			continue
+		}
+		if t, ok := newest[w.Meta.UID]; ok {
+			workloads[i].Deployed = t
+		} else {
+			workloads[i].Deployed = w.Meta.CreationTimestamp.Time
+		}
+	}
+	return nil
+}
+
+// restartCounts returns the container restarts of the pods of each
+// workload by container name
+func (k *KubeAPI) restartCounts(selector string, workloads []workload) ([]map[string]int32, error) {
+	pods, err := k.Client.CoreV1().Pods(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	restarts := make([]map[string]int32, len(workloads))
+	for i, w := range workloads {
+		restarts[i] = map[string]int32{}
+		if w.Selector == nil {
+			continue
+		}
+		s, err := metav1.LabelSelectorAsSelector(w.Selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods.Items {
+			if !s.Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			for _, cs := range pod.Status.ContainerStatuses {
+				restarts[i][cs.Name] += cs.RestartCount
+			}
+		}
+	}
+	return restarts, nil
+}
+
+// clusterTags returns the image tags of the workload containers of an
+// app/env in each cluster by group and container|kind|workload name
+func clusterTags(clients []*KubeAPI, app, env string) (map[string]map[string][]string, error) {
+	rows := map[string]map[string][]string{}
+	for i, k := range clients {
+		workloads, err := k.listWorkloads(listSelector(app, env))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", k.Cluster, err)
+		}
+		for _, w := range workloads {
+			group := w.Template.ObjectMeta.Labels[groupLabel()]
+			if rows[group] == nil {
+				rows[group] = map[string][]string{}
+			}
+			for _, container := range w.Template.Spec.Containers {
+				id := container.Name + "|" + w.Kind + "|" + w.Name
+				if rows[group][id] == nil {
+					rows[group][id] = make([]string, len(clients))
+				}
+				var tag string
+				if img, err := docker.ParseImage(container.Image); err == nil {
+					tag = img.Version()
+				}
+				rows[group][id][i] = tag
+			}
+		}
+	}
+	return rows, nil
+}
+
+// ListClusters displays the tags of k8s workloads matching given app/env
+// side by side for each cluster
+func ListClusters(clients []*KubeAPI, app, env string) error {
+	if env == "" {
+		env = "stage|production"
+	}
+	header := []string{"ID", "Kind", "Name"}
+	for _, k := range clients {
+		header = append(header, k.Cluster)
+	}
+	rows, err := clusterTags(clients, app, env)
+	if err != nil {
+		return err
+	}
+
+	var groups []string
+	for group := range rows {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		var ids []string
+		for id := range rows[group] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		var data [][]string
+		for _, id := range ids {
+			p := strings.SplitN(id, "|", 3)
+			row := []string{cyan(p[0]), p[1], p[2]}
+			for _, tag := range rows[group][id] {
+				if tag == "" {
+					tag = "-"
+				}
+				row = append(row, white(tag))
+			}
+			data = append(data, row)
+		}
+
+		fmt.Println(green(group))
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader(header)
+		table.AppendBulk(data)
+		table.Render()
+	}
+
+	return nil
 }
 
-func collectStatefulSetGroups(deploymentList *apiv1.StatefulSetList, app string, env string, groups deploymentGroups) deploymentGroups {
-	for _, item := range deploymentList.Items {
-		group := item.Spec.Template.ObjectMeta.Labels["group"]
-		groupEnv := item.Spec.Template.ObjectMeta.Labels["env"]
-		replicas := item.Status.Replicas
-		for _, container := range item.Spec.Template.Spec.Containers {
-			groups = addContainerToGroup(groups, app, env, group, groupEnv, replicas, container)
+// listSelector returns the label selector of the workloads of an app (or
+// all apps if empty) in any of the |-separated envs
+func listSelector(app, env string) string {
+	envs := strings.Split(env, "|")
+	if app == "" {
+		return fmt.Sprintf("%s in (%s)", envLabel(), strings.Join(envs, ","))
+	}
+	var groups []string
+	for _, e := range envs {
+		groups = append(groups, fmt.Sprintf("%s-%s", app, e))
+	}
+	return fmt.Sprintf("%s in (%s)", groupLabel(), strings.Join(groups, ","))
+}
+
+func collectGroups(workloads []workload, restarts []map[string]int32, app string, env string, wide bool) deploymentGroups {
+	groups := deploymentGroups{}
+	for i, w := range workloads {
+		for _, container := range w.Template.Spec.Containers {
+			groups = addContainerToGroup(groups, app, env, w, restarts[i][container.Name], container, wide)
 		}
 	}
 
 	return groups
 }
 
-func addContainerToGroup(groups deploymentGroups, app, env, group, groupEnv string, replicas int32, container corev1.Container) deploymentGroups {
-	var data = make([][]string, 10)
-	parts := strings.Split(container.Image, ":")
+func addContainerToGroup(groups deploymentGroups, app, env string, w workload, restarts int32, container corev1.Container, wide bool) deploymentGroups {
+	group := w.Template.ObjectMeta.Labels[groupLabel()]
+	groupEnv := w.Template.ObjectMeta.Labels[envLabel()]
+
 	var tag string
-	if len(parts) > 1 {
-		tag = parts[1]
-	}
-	cpu := container.Resources.Limits["cpu"]
-	mem := container.Resources.Limits["memory"]
-	data = append(data, []string{
-		cyan(container.Name),
-		white(tag),
-		yellow(replicas),
-		cyan(cpu.String()),
-		cyan(mem.String()),
-	})
-
-	parts = strings.Split(group, "-")
+	if img, err := docker.ParseImage(container.Image); err == nil {
+		tag = img.Version()
+	}
+	ready := fmt.Sprintf("%d/%d", w.Ready, w.Desired)
+	if w.Ready < w.Desired {
+		ready = yellow(ready)
+	} else {
+		ready = green(ready)
+	}
+	restarted := fmt.Sprint(restarts)
+	if restarts > 0 {
+		restarted = yellow(restarted)
+	}
+	age := "-"
+	if !w.Deployed.IsZero() {
+		age = duration.HumanDuration(time.Since(w.Deployed))
+	}
+	cpu := resourceRange(container.Resources, corev1.ResourceCPU)
+	mem := resourceRange(container.Resources, corev1.ResourceMemory)
+
+	var data []string
+	if wide {
+		data = []string{
+			cyan(container.Name),
+			w.Kind,
+			w.Name,
+			white(container.Image),
+			ready,
+			fmt.Sprint(w.Updated),
+			restarted,
+			age,
+			cyan(cpu),
+			cyan(mem),
+			w.Status,
+		}
+	} else {
+		status := w.Status
+		switch {
+		case w.Kind == "cronjob" || w.Kind == "job":
+		case w.RollingOut:
+			status = yellow("rolling out")
+		default:
+			status = green("ok")
+		}
+		data = []string{
+			cyan(container.Name),
+			w.Kind,
+			white(tag),
+			ready,
+			restarted,
+			age,
+			cyan(cpu),
+			cyan(mem),
+			status,
+		}
+	}
+
+	parts := strings.Split(group, "-")
 	a := strings.Join(parts[:len(parts)-1], "-")
 
 	for _, e := range strings.Split(env, "|") {
 		if groupEnv == e {
 			if app == "" || a == app {
-				groups[group] = append(groups[group], data...)
+				groups[group] = append(groups[group], data)
 			}
 		}
 	}
 
 	return groups
 }
+
+// resourceRange formats the request and limit of a container resource
+// e.g., 100m/500m
+func resourceRange(resources corev1.ResourceRequirements, name corev1.ResourceName) string {
+	format := func(list corev1.ResourceList) string {
+		if q, ok := list[name]; ok {
+			return q.String()
+		}
+		return "-"
+	}
+	return fmt.Sprintf("%s/%s", format(resources.Requests), format(resources.Limits))
+}
@@ -0,0 +1,112 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCollectGroups(t *testing.T) {
+	labels := map[string]string{"group": "foo-production", "env": "production"}
+	replicas := int32(2)
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-web", Namespace: "test", Labels: labels},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: labels},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: labels},
+						Spec: corev1.PodSpec{Containers: []corev1.Container{
+							{Name: "web", Image: "quay.io/foo:v1"},
+							{Name: "proxy", Image: "quay.io/proxy:v2"},
+						}},
+					},
+				},
+			},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-web-1", Namespace: "test", Labels: labels},
+				Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{
+					{Name: "web", RestartCount: 2},
+					{Name: "proxy"},
+				}},
+			},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-web-2", Namespace: "test", Labels: labels},
+				Status:     corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "web", RestartCount: 1}}},
+			},
+		),
+		Namespace: "test",
+	}
+
+	selector := listSelector("foo", "production")
+	workloads, err := k.listWorkloads(selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restarts, err := k.restartCounts(selector, workloads)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restarts[0]["web"] != 3 {
+		t.Errorf("expected 3 web restarts got %d", restarts[0]["web"])
+	}
+
+	groups := collectGroups(workloads, restarts, "foo", "production", false)
+	if len(groups["foo-production"]) != 2 {
+		t.Errorf("expected one row per container got %d rows", len(groups["foo-production"]))
+	}
+}
+
+func TestResourceRange(t *testing.T) {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+		Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+	}
+	if got := resourceRange(resources, corev1.ResourceCPU); got != "100m/500m" {
+		t.Errorf("expected 100m/500m got %s", got)
+	}
+	if got := resourceRange(resources, corev1.ResourceMemory); got != "128Mi/-" {
+		t.Errorf("expected 128Mi/- got %s", got)
+	}
+}
+
+func TestClusterTags(t *testing.T) {
+	labels := map[string]string{"group": "foo-production"}
+	deployment := func(name, tag string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: labels},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "quay.io/foo:" + tag}}},
+				},
+			},
+		}
+	}
+	clients := []*KubeAPI{
+		{Client: fake.NewSimpleClientset(deployment("foo-web", "v2"), deployment("foo-worker", "v1")), Cluster: "kube-us-east", Namespace: "test"},
+		{Client: fake.NewSimpleClientset(deployment("foo-web", "v2")), Cluster: "kube-eu-west", Namespace: "test"},
+	}
+
+	rows, err := clusterTags(clients, "foo", "production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// workloads running containers of the same name keep their own rows
+	exp := map[string]map[string][]string{
+		"foo-production": {
+			"foo|deployment|foo-web":    {"v2", "v2"},
+			"foo|deployment|foo-worker": {"v1", ""},
+		},
+	}
+	if !reflect.DeepEqual(rows, exp) {
+		t.Errorf("expected %v got %v", exp, rows)
+	}
+}
@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// logColors are cycled through to tell the pods of a group apart
+var logColors = []func(a ...interface{}) string{
+	cyan,
+	green,
+	yellow,
+	color.New(color.FgMagenta, color.Bold).SprintFunc(),
+	color.New(color.FgBlue, color.Bold).SprintFunc(),
+	red,
+}
+
+// LogOptions configures which logs Logs streams
+type LogOptions struct {
+	// Follow keeps streaming new log lines until interrupted
+	Follow bool
+	// Since only shows logs newer than a duration, e.g. 10m
+	Since time.Duration
+	// Container only shows the logs of containers of this name
+	Container string
+}
+
+// logStream is a container of a pod to stream logs from
+type logStream struct {
+	Pod       string
+	Container string
+	// Prefix names the stream on every line
+	Prefix string
+}
+
+// Logs streams the logs of every pod of an app/env group (canaries
+// included) to out at the same time. Each line is prefixed with a colored
+// pod name (and container name if the pods run several)
+func (k *KubeAPI) Logs(app, env string, opts LogOptions, out io.Writer) error {
+	streams, err := k.logStreams(app, env, opts.Container)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for i, s := range streams {
+		wg.Add(1)
+		go func(i int, s logStream) {
+			defer wg.Done()
+			prefix := logColors[i%len(logColors)](s.Prefix)
+			if err := k.streamLogs(s, opts, prefix, &mu, out); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %s", s.Prefix, err))
+				mu.Unlock()
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		fmt.Fprintln(out, red(err))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not stream logs of %d of %d containers", len(errs), len(streams))
+	}
+	return nil
+}
+
+// logStreams returns the pod containers of an app/env group to stream logs
+// from, sorted by pod name
+func (k *KubeAPI) logStreams(app, env, container string) ([]logStream, error) {
+	selector := fmt.Sprintf("%s=%s-%s", groupLabel(), app, env)
+	pods, err := k.Client.CoreV1().Pods(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	var streams []logStream
+	for _, pod := range pods.Items {
+		for _, c := range pod.Spec.Containers {
+			if container != "" && c.Name != container {
+				continue
+			}
+			prefix := pod.Name
+			if container == "" && len(pod.Spec.Containers) > 1 {
+				prefix = fmt.Sprintf("%s/%s", pod.Name, c.Name)
+			}
+			streams = append(streams, logStream{Pod: pod.Name, Container: c.Name, Prefix: prefix})
+		}
+	}
+	if len(streams) == 0 {
+		if container != "" {
+			return nil, fmt.Errorf("no pods running container %s found for %s-%s", container, app, env)
+		}
+		return nil, fmt.Errorf("no pods found for %s-%s", app, env)
+	}
+	return streams, nil
+}
+
+// streamLogs copies the log lines of a pod container to out, holding mu
+// while writing so that lines of concurrent streams are not interleaved
+func (k *KubeAPI) streamLogs(s logStream, opts LogOptions, prefix string, mu *sync.Mutex, out io.Writer) error {
+	podOpts := &corev1.PodLogOptions{Container: s.Container, Follow: opts.Follow}
+	if opts.Since > 0 {
+		seconds := int64(opts.Since.Seconds())
+		podOpts.SinceSeconds = &seconds
+	}
+	stream, err := k.Client.CoreV1().Pods(k.Namespace).GetLogs(s.Pod, podOpts).Stream(context.Background())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		mu.Lock()
+		fmt.Fprintf(out, "%s %s\n", prefix, scanner.Text())
+		mu.Unlock()
+	}
+	return scanner.Err()
+}
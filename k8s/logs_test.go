@@ -0,0 +1,54 @@
+package k8s
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLogStreams(t *testing.T) {
+	pod := func(name, group string, containers ...string) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: map[string]string{"group": group}}}
+		for _, c := range containers {
+			p.Spec.Containers = append(p.Spec.Containers, corev1.Container{Name: c})
+		}
+		return p
+	}
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			pod("foo-web-2", "foo-production", "web"),
+			pod("foo-web-1", "foo-production", "web"),
+			pod("foo-worker-1", "foo-production", "worker", "proxy"),
+			pod("foo-web-3", "foo-stage", "web"),
+		),
+		Namespace: "test",
+	}
+
+	cases := []struct {
+		container string
+		exp       []string
+	}{
+		{exp: []string{"foo-web-1", "foo-web-2", "foo-worker-1/worker", "foo-worker-1/proxy"}},
+		{container: "proxy", exp: []string{"foo-worker-1"}},
+	}
+	for _, test := range cases {
+		streams, err := k.logStreams("foo", "production", test.container)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(streams) != len(test.exp) {
+			t.Fatalf("expected %d streams got %d", len(test.exp), len(streams))
+		}
+		for i, s := range streams {
+			if s.Prefix != test.exp[i] {
+				t.Errorf("expected stream %s got %s", test.exp[i], s.Prefix)
+			}
+		}
+	}
+
+	if _, err := k.logStreams("foo", "production", "missing"); err == nil {
+		t.Error("expected error when no pods run the container")
+	}
+}
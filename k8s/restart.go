@@ -0,0 +1,62 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// restartedAtAnnotation is the pod template annotation kubectl rollout
+// restart sets, changing it rolls every pod of a workload
+const restartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// Restart triggers a rolling restart of every Deployment, StatefulSet and
+// DaemonSet of an app/env group, e.g. so pods pick up changed ENV vars
+func (k *KubeAPI) Restart(app, env string) error {
+	patch := []byte(fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{%q:%q}}}}}`, restartedAtAnnotation, time.Now().Format(time.RFC3339)))
+	restarted := 0
+
+	deployments, err := k.groupDeployments(app, env)
+	if err != nil {
+		return err
+	}
+	for _, d := range deployments {
+		fmt.Printf("restarting deployment/%s\n", d.Name)
+		if _, err := k.Client.AppsV1().Deployments(k.Namespace).Patch(context.Background(), d.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("could not restart deployment/%s: %v", d.Name, err)
+		}
+		restarted++
+	}
+
+	statefulSets, err := k.groupStatefulSets(app, env)
+	if err != nil {
+		return err
+	}
+	for _, ss := range statefulSets {
+		fmt.Printf("restarting statefulset/%s\n", ss.Name)
+		if _, err := k.Client.AppsV1().StatefulSets(k.Namespace).Patch(context.Background(), ss.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("could not restart statefulset/%s: %v", ss.Name, err)
+		}
+		restarted++
+	}
+
+	daemonSets, err := k.groupDaemonSets(app, env)
+	if err != nil {
+		return err
+	}
+	for _, ds := range daemonSets {
+		fmt.Printf("restarting daemonset/%s\n", ds.Name)
+		if _, err := k.Client.AppsV1().DaemonSets(k.Namespace).Patch(context.Background(), ds.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return fmt.Errorf("could not restart daemonset/%s: %v", ds.Name, err)
+		}
+		restarted++
+	}
+
+	if restarted == 0 {
+		return fmt.Errorf("nothing to restart for %s-%s", app, env)
+	}
+	return nil
+}
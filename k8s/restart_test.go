@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRestart(t *testing.T) {
+	meta := func(name, group string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "test", Labels: map[string]string{"group": group}}
+	}
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			&appsv1.Deployment{ObjectMeta: meta("foo-web", "foo-stage")},
+			&appsv1.StatefulSet{ObjectMeta: meta("foo-db", "foo-stage")},
+			&appsv1.Deployment{ObjectMeta: meta("foo-web-prod", "foo-production")},
+		),
+		Namespace: "test",
+	}
+
+	if err := k.Restart("foo", "stage"); err != nil {
+		t.Fatal(err)
+	}
+	d, err := k.Client.AppsV1().Deployments("test").Get(context.Background(), "foo-web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Spec.Template.Annotations[restartedAtAnnotation] == "" {
+		t.Error("expected deployment/foo-web to be restarted")
+	}
+	ss, err := k.Client.AppsV1().StatefulSets("test").Get(context.Background(), "foo-db", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss.Spec.Template.Annotations[restartedAtAnnotation] == "" {
+		t.Error("expected statefulset/foo-db to be restarted")
+	}
+	d, err = k.Client.AppsV1().Deployments("test").Get(context.Background(), "foo-web-prod", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.Spec.Template.Annotations[restartedAtAnnotation]; ok {
+		t.Error("expected deployment/foo-web-prod of another group not to be restarted")
+	}
+
+	if err := k.Restart("bar", "stage"); err == nil {
+		t.Error("expected error restarting a group with no workloads")
+	}
+}
@@ -0,0 +1,149 @@
+package k8s
+
+import (
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// rolloutInterval is how often rollout progress is polled
+var rolloutInterval = 2 * time.Second
+
+// WaitForRollout blocks until every Deployment, StatefulSet and DaemonSet
+// in the app/env group is fully rolled out, printing progress as it changes.
+// An error is returned if the rollout does not complete within timeout
+func (k *KubeAPI) WaitForRollout(app, env string, timeout time.Duration) error {
+	progress := map[string]string{}
+	report := func(name, msg string) {
+		if progress[name] != msg {
+			line := fmt.Sprintf("  %s: %s\n", cyan(name), msg)
+			if k.Prefix != "" {
+				line = fmt.Sprintf("%s:%s", k.Prefix, line)
+			}
+			fmt.Print(line)
+			progress[name] = msg
+		}
+	}
+
+	err := wait.PollImmediate(rolloutInterval, timeout, func() (bool, error) {
+		deployments, err := k.groupDeployments(app, env)
+		if err != nil {
+			return false, err
+		}
+		statefulSets, err := k.groupStatefulSets(app, env)
+		if err != nil {
+			return false, err
+		}
+		daemonSets, err := k.groupDaemonSets(app, env)
+		if err != nil {
+			return false, err
+		}
+		if len(deployments) == 0 && len(statefulSets) == 0 && len(daemonSets) == 0 {
+			// CronJobs have nothing to roll out but a group of only
+			// CronJobs has still been deployed
+			workloads, err := k.groupWorkloads(app, env)
+			if err != nil {
+				return false, err
+			}
+			if len(workloads) == 0 {
+				return false, fmt.Errorf("no workloads found for %s-%s", app, env)
+			}
+			return true, nil
+		}
+
+		done := true
+		for _, d := range deployments {
+			msg, ok, err := deploymentRolloutStatus(d)
+			if err != nil {
+				return false, err
+			}
+			report("deployment/"+d.Name, msg)
+			done = done && ok
+		}
+		for _, ss := range statefulSets {
+			msg, ok := statefulSetRolloutStatus(ss)
+			report("statefulset/"+ss.Name, msg)
+			done = done && ok
+		}
+		for _, ds := range daemonSets {
+			msg, ok := daemonSetRolloutStatus(ds)
+			report("daemonset/"+ds.Name, msg)
+			done = done && ok
+		}
+		return done, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("rollout of %s-%s did not complete within %s", app, env, timeout)
+	}
+	return err
+}
+
+// deploymentRolloutStatus summarizes the rollout progress of a Deployment
+// and whether it has completed
+func deploymentRolloutStatus(d appsv1.Deployment) (string, bool, error) {
+	if d.Status.ObservedGeneration < d.Generation {
+		return "waiting for rollout to start", false, nil
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return "", false, fmt.Errorf("deployment/%s exceeded its progress deadline", d.Name)
+		}
+	}
+
+	var replicas int32 = 1
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	if d.Status.UpdatedReplicas < replicas {
+		return fmt.Sprintf("%d of %d replicas updated", d.Status.UpdatedReplicas, replicas), false, nil
+	}
+	if d.Status.Replicas > d.Status.UpdatedReplicas {
+		return fmt.Sprintf("%d old replicas pending termination", d.Status.Replicas-d.Status.UpdatedReplicas), false, nil
+	}
+	if d.Status.AvailableReplicas < d.Status.UpdatedReplicas {
+		return fmt.Sprintf("%d of %d updated replicas available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas), false, nil
+	}
+
+	return green(fmt.Sprintf("rolled out (%d of %d available)", d.Status.AvailableReplicas, replicas)), true, nil
+}
+
+// statefulSetRolloutStatus summarizes the rollout progress of a StatefulSet
+// and whether it has completed
+func statefulSetRolloutStatus(ss appsv1.StatefulSet) (string, bool) {
+	if ss.Status.ObservedGeneration < ss.Generation {
+		return "waiting for rollout to start", false
+	}
+
+	var replicas int32 = 1
+	if ss.Spec.Replicas != nil {
+		replicas = *ss.Spec.Replicas
+	}
+	if ss.Spec.UpdateStrategy.Type == appsv1.RollingUpdateStatefulSetStrategyType && ss.Status.UpdateRevision != ss.Status.CurrentRevision {
+		return fmt.Sprintf("%d of %d replicas updated", ss.Status.UpdatedReplicas, replicas), false
+	}
+	if ss.Status.ReadyReplicas < replicas {
+		return fmt.Sprintf("%d of %d replicas ready", ss.Status.ReadyReplicas, replicas), false
+	}
+
+	return green(fmt.Sprintf("rolled out (%d of %d ready)", ss.Status.ReadyReplicas, replicas)), true
+}
+
+// daemonSetRolloutStatus summarizes the rollout progress of a DaemonSet
+// and whether it has completed
+func daemonSetRolloutStatus(ds appsv1.DaemonSet) (string, bool) {
+	if ds.Status.ObservedGeneration < ds.Generation {
+		return "waiting for rollout to start", false
+	}
+
+	desired := ds.Status.DesiredNumberScheduled
+	if ds.Spec.UpdateStrategy.Type == appsv1.RollingUpdateDaemonSetStrategyType && ds.Status.UpdatedNumberScheduled < desired {
+		return fmt.Sprintf("%d of %d pods updated", ds.Status.UpdatedNumberScheduled, desired), false
+	}
+	if ds.Status.NumberAvailable < desired {
+		return fmt.Sprintf("%d of %d updated pods available", ds.Status.NumberAvailable, desired), false
+	}
+
+	return green(fmt.Sprintf("rolled out (%d of %d available)", ds.Status.NumberAvailable, desired)), true
+}
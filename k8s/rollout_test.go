@@ -0,0 +1,131 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func TestDeploymentRolloutStatus(t *testing.T) {
+	replicas := int32(3)
+	cases := []struct {
+		generation int64
+		status     appsv1.DeploymentStatus
+		done       bool
+		fails      bool
+	}{
+		{generation: 2, status: appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3}, done: false},
+		{generation: 2, status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 3}, done: false},
+		{generation: 2, status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 4, UpdatedReplicas: 3, AvailableReplicas: 3}, done: false},
+		{generation: 2, status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 2}, done: false},
+		{generation: 2, status: appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 3, AvailableReplicas: 3}, done: true},
+		{generation: 2, status: appsv1.DeploymentStatus{ObservedGeneration: 2, Conditions: []appsv1.DeploymentCondition{
+			{Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded"},
+		}}, fails: true},
+	}
+	for _, test := range cases {
+		d := appsv1.Deployment{Status: test.status}
+		d.Generation = test.generation
+		d.Spec.Replicas = &replicas
+		_, done, err := deploymentRolloutStatus(d)
+		if test.fails && err == nil {
+			t.Errorf("expected error for status %+v", test.status)
+		}
+		if !test.fails && err != nil {
+			t.Errorf("unexpected error for status %+v: %s", test.status, err)
+		}
+		if done != test.done {
+			t.Errorf("expected done=%v for status %+v", test.done, test.status)
+		}
+	}
+}
+
+// TestRollbackDeploy checks that deploying the previous tag after a failed
+// deploy, as deploy --rollback does, reverts every workload
+func TestRollbackDeploy(t *testing.T) {
+	labels := map[string]string{"group": "foo-production"}
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "foo", Image: "quay.io/foo:v1"}}},
+	}
+	meta := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "test", Labels: labels}
+	}
+	stuck := appsv1.DeploymentStatus{Conditions: []appsv1.DeploymentCondition{
+		{Type: appsv1.DeploymentProgressing, Reason: "ProgressDeadlineExceeded"},
+	}}
+	tags := func(k *KubeAPI) []string {
+		deployments, err := k.groupDeployments("foo", "production")
+		if err != nil {
+			t.Fatal(err)
+		}
+		statefulSets, err := k.groupStatefulSets("foo", "production")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var tags []string
+		for _, d := range deployments {
+			tags = append(tags, findTag("foo", "", d.Spec.Template))
+		}
+		for _, ss := range statefulSets {
+			tags = append(tags, findTag("foo", "", ss.Spec.Template))
+		}
+		return tags
+	}
+	newClient := func() *fake.Clientset {
+		return fake.NewSimpleClientset(
+			&appsv1.Deployment{ObjectMeta: meta("foo-web"), Spec: appsv1.DeploymentSpec{Template: template}, Status: stuck},
+			&appsv1.Deployment{ObjectMeta: meta("foo-worker"), Spec: appsv1.DeploymentSpec{Template: template}},
+			&appsv1.StatefulSet{ObjectMeta: meta("foo-db"), Spec: appsv1.StatefulSetSpec{Template: template}},
+		)
+	}
+
+	// the rollout fails
+	k := &KubeAPI{Client: newClient(), Namespace: "test"}
+	if err := k.Deploy("foo", "production", "v2", "foo", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.WaitForRollout("foo", "production", time.Second); err == nil {
+		t.Fatal("expected rollout to fail")
+	}
+	if err := k.Deploy("foo", "production", "v1", "foo", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range tags(k) {
+		if tag != "v1" {
+			t.Errorf("expected every workload to be rolled back to v1 got %v", tags(k))
+			break
+		}
+	}
+
+	// the deploy fails after updating the Deployments
+	client := newClient()
+	failed := false
+	client.PrependReactor("update", "statefulsets", func(action ktesting.Action) (bool, runtime.Object, error) {
+		if !failed {
+			failed = true
+			return true, nil, errors.New("conflict")
+		}
+		return false, nil, nil
+	})
+	k = &KubeAPI{Client: client, Namespace: "test"}
+	if err := k.Deploy("foo", "production", "v2", "foo", ""); err == nil {
+		t.Fatal("expected deploy to fail")
+	}
+	if err := k.Deploy("foo", "production", "v1", "foo", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range tags(k) {
+		if tag != "v1" {
+			t.Errorf("expected every workload to be rolled back to v1 got %v", tags(k))
+			break
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// runLabel labels the Jobs (and their pods) started by RunJob
+const runLabel = "duncan-run"
+
+// RunOptions configures a one-off Job run by RunJob
+type RunOptions struct {
+	Command []string
+	// Container is the container of the pod template to run the command
+	// in, defaults to the container running Repo (or the first container)
+	Container string
+	// Tag, if set, replaces the image tag of containers running Repo (or
+	// Container) e.g. to run migrations of a tag before it is deployed
+	Tag, Repo string
+	// Env is added to the environment of the command
+	Env map[string]string
+	// Timeout limits how long the Job may run, defaults to an hour
+	Timeout time.Duration
+	// Out receives the logs of the Job
+	Out io.Writer
+}
+
+// RunJob runs a command as a Job created from the pod template of an
+// app/env group's Deployment so it gets the same image, ENV and config.
+// Logs are streamed to opts.Out until the Job finishes and the Job is
+// deleted afterwards. An error is returned if the command fails
+func (k *KubeAPI) RunJob(app, env string, opts RunOptions) error {
+	if opts.Timeout == 0 {
+		opts.Timeout = time.Hour
+	}
+	job, err := k.runJob(app, env, opts)
+	if err != nil {
+		return err
+	}
+	jobs := k.Client.BatchV1().Jobs(k.Namespace)
+	job, err = jobs.Create(context.Background(), job, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("could not create job: %s", err)
+	}
+	fmt.Fprintf(opts.Out, "started job %s\n", job.Name)
+	defer func() {
+		background := metav1.DeletePropagationBackground
+		if err := jobs.Delete(context.Background(), job.Name, metav1.DeleteOptions{PropagationPolicy: &background}); err != nil {
+			fmt.Fprintf(opts.Out, "WARNING: could not delete job %s: %s\n", job.Name, err)
+		}
+	}()
+
+	container := job.Spec.Template.Spec.Containers[0].Name
+	pod, err := k.waitForRunPod(job.Name, container, opts.Timeout)
+	if err != nil {
+		return err
+	}
+	var mu sync.Mutex
+	s := logStream{Pod: pod, Container: container, Prefix: job.Name}
+	if err := k.streamLogs(s, LogOptions{Follow: true}, cyan(s.Prefix), &mu, opts.Out); err != nil {
+		fmt.Fprintf(opts.Out, "WARNING: could not stream logs of %s: %s\n", job.Name, err)
+	}
+
+	err = wait.PollImmediate(time.Second, opts.Timeout, func() (bool, error) {
+		j, err := jobs.Get(context.Background(), job.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if j.Status.Succeeded > 0 {
+			return true, nil
+		}
+		if j.Status.Failed > 0 {
+			return false, fmt.Errorf("job %s failed: %s", job.Name, strings.Join(opts.Command, " "))
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("job %s did not complete within %s", job.Name, opts.Timeout)
+	}
+	return err
+}
+
+// runJob returns the Job running a command of RunJob. Only the chosen
+// container is kept (sidecars would keep the Job from completing) and its
+// probes are removed. The pods are not labeled with the app/env group so
+// that Services do not route traffic to them
+func (k *KubeAPI) runJob(app, env string, opts RunOptions) (*batchv1.Job, error) {
+	if len(opts.Command) == 0 {
+		return nil, fmt.Errorf("a command to run is required")
+	}
+	deployments, err := k.groupDeployments(app, env)
+	if err != nil {
+		return nil, err
+	}
+	var template *corev1.PodTemplateSpec
+	for _, d := range deployments {
+		if opts.Container == "" || hasContainer(d.Spec.Template, opts.Container) {
+			template = d.Spec.Template.DeepCopy()
+			break
+		}
+	}
+	if opts.Container == "" && opts.Repo != "" {
+		// prefer a Deployment running the app's image over e.g. one only
+		// running a sidecar
+		for _, d := range deployments {
+			if runContainer(d.Spec.Template, opts) >= 0 {
+				template = d.Spec.Template.DeepCopy()
+				break
+			}
+		}
+	}
+	if template == nil {
+		if opts.Container != "" {
+			return nil, fmt.Errorf("no deployment running container %s found for %s-%s", opts.Container, app, env)
+		}
+		return nil, fmt.Errorf("no deployments found for %s-%s", app, env)
+	}
+	if opts.Tag != "" {
+		setImage("job", "", template, opts.Tag, opts.Repo, opts.Container)
+	}
+
+	c := template.Spec.Containers[0]
+	if i := runContainer(*template, opts); i >= 0 {
+		c = template.Spec.Containers[i]
+	}
+	c.Command, c.Args = opts.Command, nil
+	var names []string
+	for name := range opts.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c.Env = append(c.Env, corev1.EnvVar{Name: name, Value: opts.Env[name]})
+	}
+	c.LivenessProbe, c.ReadinessProbe, c.StartupProbe = nil, nil, nil
+	c.Ports = nil
+	template.Spec.Containers = []corev1.Container{c}
+	template.Spec.RestartPolicy = corev1.RestartPolicyNever
+
+	// the random suffix keeps runs started in the same second apart
+	name := fmt.Sprintf("%s-%s-run-%d-%s", app, env, time.Now().Unix(), rand.String(5))
+	if len(name) > 63 {
+		name = name[len(name)-63:]
+		name = strings.TrimLeft(name, "-")
+	}
+	template.ObjectMeta.Labels = map[string]string{envLabel(): env, runLabel: name}
+	delete(template.ObjectMeta.Annotations, restartedAtAnnotation)
+
+	var backoff int32
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: k.Namespace,
+			Labels:    map[string]string{groupLabel(): fmt.Sprintf("%s-%s", app, env), envLabel(): env, runLabel: name},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoff,
+			Template:     *template,
+		},
+	}, nil
+}
+
+// runContainer returns the index of the container of a pod template to run
+// the command of RunJob in: the container named Container or else the one
+// running Repo. -1 is returned if there is none
+func runContainer(template corev1.PodTemplateSpec, opts RunOptions) int {
+	return containerIndex(template.Spec.Containers, opts.Repo, opts.Container)
+}
+
+// containerIndex returns the index of the container named container, or
+// else of the one running repo. -1 is returned if there is none
+func containerIndex(containers []corev1.Container, repo, container string) int {
+	if container == "" && repo == "" {
+		return -1
+	}
+	for i, c := range containers {
+		if matchesContainer(c, repo, container) {
+			return i
+		}
+	}
+	return -1
+}
+
+// waitForRunPod returns the pod of a Job once its container has started
+func (k *KubeAPI) waitForRunPod(job, container string, timeout time.Duration) (string, error) {
+	var pod string
+	err := wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		list, err := k.Client.CoreV1().Pods(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", runLabel, job)})
+		if err != nil {
+			return false, err
+		}
+		for _, p := range list.Items {
+			for _, cs := range p.Status.ContainerStatuses {
+				if cs.Name != container {
+					continue
+				}
+				if cs.State.Running != nil || cs.State.Terminated != nil {
+					pod = p.Name
+					return true, nil
+				}
+				if w := cs.State.Waiting; w != nil && (w.Reason == "ErrImagePull" || w.Reason == "ImagePullBackOff" || w.Reason == "CreateContainerConfigError") {
+					return false, fmt.Errorf("job %s cannot start: %s", job, w.Reason)
+				}
+			}
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return "", fmt.Errorf("job %s did not start within %s", job, timeout)
+	}
+	return pod, err
+}
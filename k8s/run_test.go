@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRunJob(t *testing.T) {
+	labels := map[string]string{"group": "foo-production", "env": "production"}
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-web", Namespace: "test", Labels: labels},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{
+						{
+							Name:           "web",
+							Image:          "quay.io/foo:v1",
+							Args:           []string{"server"},
+							Env:            []corev1.EnvVar{{Name: "DATABASE_URL", Value: "postgres://db"}},
+							ReadinessProbe: &corev1.Probe{},
+						},
+						{Name: "proxy", Image: "quay.io/proxy:v2"},
+					}},
+				},
+			},
+		}),
+		Namespace: "test",
+	}
+
+	job, err := k.runJob("foo", "production", RunOptions{Command: []string{"rake", "db:migrate"}, Tag: "v2", Container: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := job.Spec.Template.Spec
+	if len(spec.Containers) != 1 {
+		t.Fatalf("expected only the web container got %d containers", len(spec.Containers))
+	}
+	c := spec.Containers[0]
+	if c.Image != "quay.io/foo:v2" {
+		t.Errorf("expected image quay.io/foo:v2 got %s", c.Image)
+	}
+	if len(c.Command) != 2 || c.Command[0] != "rake" || c.Args != nil {
+		t.Errorf("expected command to be overridden got %v %v", c.Command, c.Args)
+	}
+	if len(c.Env) != 1 || c.ReadinessProbe != nil {
+		t.Errorf("expected env to be kept and probes removed got %v %v", c.Env, c.ReadinessProbe)
+	}
+	if spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Errorf("expected restart policy Never got %s", spec.RestartPolicy)
+	}
+	if _, ok := job.Spec.Template.Labels["group"]; ok {
+		t.Error("expected job pods not to be labeled with the group")
+	}
+
+	other, err := k.runJob("foo", "production", RunOptions{Command: []string{"true"}, Tag: "v2", Container: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Name == job.Name {
+		t.Errorf("expected jobs started in the same second to have different names got %s", job.Name)
+	}
+
+	if _, err := k.runJob("foo", "production", RunOptions{Command: []string{"true"}, Container: "missing"}); err == nil {
+		t.Error("expected error for missing container")
+	}
+
+	// without a container the one running the repo is used, not a sidecar
+	// listed before it
+	k = &KubeAPI{
+		Client: fake.NewSimpleClientset(&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo-web", Namespace: "test", Labels: labels},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: labels},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{
+						{Name: "proxy", Image: "quay.io/proxy:v2"},
+						{Name: "web", Image: "quay.io/foo:v1"},
+					}},
+				},
+			},
+		}),
+		Namespace: "test",
+	}
+	job, err = k.runJob("foo", "production", RunOptions{Command: []string{"rake", "db:migrate"}, Tag: "v2", Repo: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = job.Spec.Template.Spec.Containers[0]
+	if c.Name != "web" || c.Image != "quay.io/foo:v2" {
+		t.Errorf("expected web container running quay.io/foo:v2 got %s running %s", c.Name, c.Image)
+	}
+}
@@ -0,0 +1,131 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// ScaleChange describes the replica count a scale will change
+type ScaleChange struct {
+	Kind string
+	Name string
+	From int32
+	To   int32
+	// Autoscaler names the HorizontalPodAutoscaler managing the
+	// replica count, if any
+	Autoscaler string
+}
+
+// ScalePlan returns the replica changes of scaling the Deployments and
+// StatefulSets of an app/env group. If id is given only the workload of
+// that name (or running a container of that name) is scaled. replicas is
+// the new replica count, or a change to the current count if relative
+func (k *KubeAPI) ScalePlan(app, env, id string, replicas int, relative bool) ([]ScaleChange, error) {
+	scaled := func(kind, name string, current *int32, template corev1.PodTemplateSpec) *ScaleChange {
+		if id != "" && name != id && !hasContainer(template, id) {
+			return nil
+		}
+		var from int32 = 1
+		if current != nil {
+			from = *current
+		}
+		to := int32(replicas)
+		if relative {
+			to = from + int32(replicas)
+		}
+		if to < 0 {
+			to = 0
+		}
+		return &ScaleChange{Kind: kind, Name: name, From: from, To: to}
+	}
+
+	var changes []ScaleChange
+	deployments, err := k.groupDeployments(app, env)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments {
+		if c := scaled("deployment", d.Name, d.Spec.Replicas, d.Spec.Template); c != nil {
+			changes = append(changes, *c)
+		}
+	}
+	statefulSets, err := k.groupStatefulSets(app, env)
+	if err != nil {
+		return nil, err
+	}
+	for _, ss := range statefulSets {
+		if c := scaled("statefulset", ss.Name, ss.Spec.Replicas, ss.Spec.Template); c != nil {
+			changes = append(changes, *c)
+		}
+	}
+	if len(changes) == 0 {
+		if id != "" {
+			return nil, fmt.Errorf("no deployment or statefulset %s found for %s-%s", id, app, env)
+		}
+		return nil, fmt.Errorf("no deployments or statefulsets found for %s-%s", app, env)
+	}
+
+	hpas, err := k.Client.AutoscalingV1().HorizontalPodAutoscalers(k.Namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i, c := range changes {
+		for _, hpa := range hpas.Items {
+			ref := hpa.Spec.ScaleTargetRef
+			if strings.EqualFold(ref.Kind, c.Kind) && ref.Name == c.Name {
+				changes[i].Autoscaler = hpa.Name
+			}
+		}
+	}
+	return changes, nil
+}
+
+// Scale sets the replica counts of a scale plan
+func (k *KubeAPI) Scale(changes []ScaleChange) error {
+	for _, c := range changes {
+		replicas := c.To
+		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			switch c.Kind {
+			case "deployment":
+				client := k.Client.AppsV1().Deployments(k.Namespace)
+				item, err := client.Get(context.Background(), c.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				item.Spec.Replicas = &replicas
+				_, err = client.Update(context.Background(), item, metav1.UpdateOptions{})
+				return err
+			case "statefulset":
+				client := k.Client.AppsV1().StatefulSets(k.Namespace)
+				item, err := client.Get(context.Background(), c.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				item.Spec.Replicas = &replicas
+				_, err = client.Update(context.Background(), item, metav1.UpdateOptions{})
+				return err
+			}
+			return fmt.Errorf("cannot scale %s", c.Kind)
+		})
+		if retryErr != nil {
+			return fmt.Errorf("could not scale %s/%s: %v", c.Kind, c.Name, retryErr)
+		}
+		fmt.Printf("scaled %s/%s from %d to %d replicas\n", c.Kind, c.Name, c.From, c.To)
+	}
+	return nil
+}
+
+// hasContainer reports whether a pod template runs a container of a name
+func hasContainer(template corev1.PodTemplateSpec, name string) bool {
+	for _, c := range template.Spec.Containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
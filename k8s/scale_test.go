@@ -0,0 +1,84 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestScalePlan(t *testing.T) {
+	deployment := func(name, container string, replicas int32) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test", Labels: map[string]string{"group": "foo-production"}},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: container}}}},
+			},
+		}
+	}
+	k := &KubeAPI{
+		Client: fake.NewSimpleClientset(
+			deployment("foo-web", "web", 3),
+			deployment("foo-worker", "worker", 1),
+			&autoscalingv1.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo-web", Namespace: "test"},
+				Spec:       autoscalingv1.HorizontalPodAutoscalerSpec{ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{Kind: "Deployment", Name: "foo-web"}},
+			},
+		),
+		Namespace: "test",
+	}
+
+	cases := []struct {
+		id       string
+		replicas int
+		relative bool
+		exp      map[string]int32
+	}{
+		{replicas: 5, exp: map[string]int32{"foo-web": 5, "foo-worker": 5}},
+		{replicas: 2, relative: true, exp: map[string]int32{"foo-web": 5, "foo-worker": 3}},
+		{replicas: -2, relative: true, exp: map[string]int32{"foo-web": 1, "foo-worker": 0}},
+		{id: "worker", replicas: 4, exp: map[string]int32{"foo-worker": 4}},
+		{id: "foo-web", replicas: 1, relative: true, exp: map[string]int32{"foo-web": 4}},
+	}
+	for _, test := range cases {
+		changes, err := k.ScalePlan("foo", "production", test.id, test.replicas, test.relative)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != len(test.exp) {
+			t.Errorf("expected %d changes got %d", len(test.exp), len(changes))
+		}
+		for _, c := range changes {
+			if c.To != test.exp[c.Name] {
+				t.Errorf("expected %s to be scaled to %d got %d", c.Name, test.exp[c.Name], c.To)
+			}
+			if autoscaled := c.Autoscaler != ""; autoscaled != (c.Name == "foo-web") {
+				t.Errorf("expected only foo-web to be autoscaled got %s autoscaled by %q", c.Name, c.Autoscaler)
+			}
+		}
+	}
+
+	if _, err := k.ScalePlan("foo", "production", "missing", 1, false); err == nil {
+		t.Error("expected error scaling an unknown id")
+	}
+
+	changes, err := k.ScalePlan("foo", "production", "worker", 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Scale(changes); err != nil {
+		t.Fatal(err)
+	}
+	d, err := k.Client.AppsV1().Deployments("test").Get(context.Background(), "foo-worker", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *d.Spec.Replicas != 2 {
+		t.Errorf("expected foo-worker to be scaled to 2 replicas got %d", *d.Spec.Replicas)
+	}
+}
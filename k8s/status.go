@@ -0,0 +1,220 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/duration"
+)
+
+// maxEvents is how many of the most recent events Status shows
+const maxEvents = 20
+
+// Status displays the workloads of an app/env group (canaries included),
+// their pods along with why containers are failing and the recent events
+// of those objects
+func (k *KubeAPI) Status(app, env string) error {
+	selector := fmt.Sprintf("%s=%s-%s", groupLabel(), app, env)
+	workloads, err := k.listWorkloads(selector)
+	if err != nil {
+		return err
+	}
+	if len(workloads) == 0 {
+		return fmt.Errorf("no workloads found for %s-%s", app, env)
+	}
+	pods, err := k.Client.CoreV1().Pods(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+	replicaSets, err := k.Client.AppsV1().ReplicaSets(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return err
+	}
+
+	objects := map[string]bool{}
+	var data [][]string
+	for _, w := range workloads {
+		objects[objectKey(w.Kind, w.Name)] = true
+		ready := fmt.Sprintf("%d/%d", w.Ready, w.Desired)
+		if w.Ready < w.Desired {
+			ready = yellow(ready)
+		} else {
+			ready = green(ready)
+		}
+		data = append(data, []string{w.Kind, cyan(w.Name), ready, fmt.Sprint(w.Updated), w.Status})
+	}
+	fmt.Println(green("workloads"))
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Kind", "Name", "Ready", "Updated", "Status"})
+	table.AppendBulk(data)
+	table.Render()
+
+	for _, rs := range replicaSets.Items {
+		objects[objectKey("replicaset", rs.Name)] = true
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+	data = nil
+	for _, pod := range pods.Items {
+		objects[objectKey("pod", pod.Name)] = true
+		var ready, total int
+		var restarts int32
+		for _, cs := range pod.Status.ContainerStatuses {
+			total++
+			if cs.Ready {
+				ready++
+			}
+			restarts += cs.RestartCount
+		}
+		readiness := fmt.Sprintf("%d/%d", ready, total)
+		if ready < total {
+			readiness = yellow(readiness)
+		}
+		restarted := fmt.Sprint(restarts)
+		if restarts > 0 {
+			restarted = yellow(restarted)
+		}
+		issues := podIssues(pod)
+		if issues != "" {
+			issues = red(issues)
+		}
+		phase := string(pod.Status.Phase)
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodUnknown {
+			phase = red(phase)
+		}
+		data = append(data, []string{
+			cyan(pod.Name),
+			phase,
+			readiness,
+			restarted,
+			issues,
+			duration.HumanDuration(time.Since(pod.CreationTimestamp.Time)),
+		})
+	}
+	fmt.Println(green("pods"))
+	table = tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Name", "Phase", "Ready", "Restarts", "Last Reason", "Age"})
+	table.AppendBulk(data)
+	table.Render()
+
+	events, err := k.groupEvents(objects)
+	if err != nil {
+		return err
+	}
+	data = nil
+	for _, e := range events {
+		reason := e.Reason
+		if e.Type == corev1.EventTypeWarning {
+			reason = red(reason)
+		}
+		data = append(data, []string{
+			duration.HumanDuration(time.Since(eventTime(e))),
+			fmt.Sprintf("%s/%s", e.InvolvedObject.Kind, e.InvolvedObject.Name),
+			reason,
+			e.Message,
+		})
+	}
+	fmt.Println(green("events"))
+	table = tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Age", "Object", "Reason", "Message"})
+	table.AppendBulk(data)
+	table.Render()
+
+	return nil
+}
+
+// podIssues returns why the containers of a pod are not running, e.g.
+// CrashLoopBackOff or OOMKilled, or the reason they last terminated
+func podIssues(pod corev1.Pod) string {
+	var reason string
+	for _, cs := range pod.Status.ContainerStatuses {
+		var r string
+		switch {
+		case cs.State.Waiting != nil && cs.State.Waiting.Reason != "":
+			r = cs.State.Waiting.Reason
+			if last := cs.LastTerminationState.Terminated; last != nil && last.Reason != "" {
+				r = fmt.Sprintf("%s (%s)", r, last.Reason)
+			}
+		case cs.State.Terminated != nil && cs.State.Terminated.Reason != "":
+			r = cs.State.Terminated.Reason
+		case cs.LastTerminationState.Terminated != nil && cs.LastTerminationState.Terminated.Reason != "":
+			r = cs.LastTerminationState.Terminated.Reason
+		}
+		if r == "" {
+			continue
+		}
+		if len(pod.Status.ContainerStatuses) > 1 {
+			r = fmt.Sprintf("%s: %s", cs.Name, r)
+		}
+		if reason != "" {
+			reason += ", "
+		}
+		reason += r
+	}
+	if reason == "" {
+		reason = pod.Status.Reason
+	}
+	return reason
+}
+
+// groupEvents returns the most recent events of the given objects (keyed by
+// objectKey), oldest first. Events are listed by object name rather than
+// listing every event of the namespace
+func (k *KubeAPI) groupEvents(objects map[string]bool) ([]corev1.Event, error) {
+	var names []string
+	seenNames := map[string]bool{}
+	for key := range objects {
+		name := key[strings.Index(key, "/")+1:]
+		if !seenNames[name] {
+			seenNames[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	seen := map[string]bool{}
+	var events []corev1.Event
+	for _, name := range names {
+		selector := fields.OneTermEqualSelector("involvedObject.name", name).String()
+		list, err := k.Client.CoreV1().Events(k.Namespace).List(context.Background(), metav1.ListOptions{FieldSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list.Items {
+			// objects of different kinds may share a name
+			if seen[e.Name] || !objects[objectKey(e.InvolvedObject.Kind, e.InvolvedObject.Name)] {
+				continue
+			}
+			seen[e.Name] = true
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return eventTime(events[i]).Before(eventTime(events[j])) })
+	if len(events) > maxEvents {
+		events = events[len(events)-maxEvents:]
+	}
+	return events, nil
+}
+
+// eventTime returns when an event last occurred
+func eventTime(e corev1.Event) time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// objectKey identifies an object by lowercase kind and name
+func objectKey(kind, name string) string {
+	return fmt.Sprintf("%s/%s", strings.ToLower(kind), name)
+}
@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	ktesting "k8s.io/client-go/testing"
+)
+
+func TestPodIssues(t *testing.T) {
+	waiting := func(reason string) corev1.ContainerState {
+		return corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: reason}}
+	}
+	terminated := func(reason string) corev1.ContainerState {
+		return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: reason}}
+	}
+	running := corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}
+
+	cases := []struct {
+		statuses []corev1.ContainerStatus
+		exp      string
+	}{
+		{statuses: []corev1.ContainerStatus{{Name: "web", State: running}}, exp: ""},
+		{statuses: []corev1.ContainerStatus{{Name: "web", State: waiting("ImagePullBackOff")}}, exp: "ImagePullBackOff"},
+		{statuses: []corev1.ContainerStatus{{Name: "web", State: waiting("CrashLoopBackOff"), LastTerminationState: terminated("OOMKilled")}}, exp: "CrashLoopBackOff (OOMKilled)"},
+		{statuses: []corev1.ContainerStatus{{Name: "web", State: running, LastTerminationState: terminated("Error")}}, exp: "Error"},
+		{
+			statuses: []corev1.ContainerStatus{
+				{Name: "web", State: running},
+				{Name: "proxy", State: terminated("Completed")},
+			},
+			exp: "proxy: Completed",
+		},
+	}
+	for _, test := range cases {
+		pod := corev1.Pod{Status: corev1.PodStatus{ContainerStatuses: test.statuses}}
+		if got := podIssues(pod); got != test.exp {
+			t.Errorf("expected %q got %q", test.exp, got)
+		}
+	}
+}
+
+func TestGroupEvents(t *testing.T) {
+	now := time.Now()
+	event := func(name, kind, object string, ago time.Duration) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "test"},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object},
+			LastTimestamp:  metav1.NewTime(now.Add(-ago)),
+		}
+	}
+	client := fake.NewSimpleClientset(
+		event("a", "Pod", "foo-web-1", time.Minute),
+		event("b", "Deployment", "foo-web", 5*time.Minute),
+		event("c", "Pod", "bar-web-1", time.Minute),
+	)
+	var selectors []string
+	client.PrependReactor("list", "events", func(action ktesting.Action) (bool, runtime.Object, error) {
+		selectors = append(selectors, action.(ktesting.ListAction).GetListRestrictions().Fields.String())
+		return false, nil, nil
+	})
+	k := &KubeAPI{Client: client, Namespace: "test"}
+
+	events, err := k.groupEvents(map[string]bool{"pod/foo-web-1": true, "deployment/foo-web": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Name != "b" || events[1].Name != "a" {
+		t.Errorf("expected events b, a got %v", events)
+	}
+	if exp := []string{"involvedObject.name=foo-web", "involvedObject.name=foo-web-1"}; !reflect.DeepEqual(selectors, exp) {
+		t.Errorf("expected events to be listed by %v got %v", exp, selectors)
+	}
+}
@@ -0,0 +1,223 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// kinds lists the workload kinds duncan manages in the order they are
+// checked for the current tag
+var kinds = []string{"deployment", "statefulset", "daemonset", "cronjob", "job"}
+
+// workload is the pod template and replica status of a Deployment,
+// StatefulSet, DaemonSet, CronJob or Job
+type workload struct {
+	Kind     string
+	Name     string
+	Meta     metav1.ObjectMeta
+	Selector *metav1.LabelSelector
+	Template corev1.PodTemplateSpec
+
+	// Desired, Ready and Updated count replicas (DaemonSet pods, active
+	// CronJob jobs or active Job pods)
+	Desired, Ready, Updated int32
+	// Status summarizes rollout progress, RollingOut is set until the
+	// rollout completes
+	Status     string
+	RollingOut bool
+	// Deployed is when the pod template was last changed, if known
+	Deployed time.Time
+}
+
+// listWorkloads returns the workloads of every kind matching a label selector
+func (k *KubeAPI) listWorkloads(selector string) ([]workload, error) {
+	opts := metav1.ListOptions{LabelSelector: selector}
+	var workloads []workload
+
+	deployments, err := k.Client.AppsV1().Deployments(k.Namespace).List(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range deployments.Items {
+		w := workload{Kind: "deployment", Name: item.Name, Meta: item.ObjectMeta, Selector: item.Spec.Selector, Template: item.Spec.Template}
+		w.Desired, w.Ready, w.Updated = 1, item.Status.ReadyReplicas, item.Status.UpdatedReplicas
+		if item.Spec.Replicas != nil {
+			w.Desired = *item.Spec.Replicas
+		}
+		msg, ok, err := deploymentRolloutStatus(item)
+		if err != nil {
+			msg = red(err.Error())
+		}
+		w.Status, w.RollingOut = msg, !ok
+		workloads = append(workloads, w)
+	}
+
+	statefulSets, err := k.Client.AppsV1().StatefulSets(k.Namespace).List(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range statefulSets.Items {
+		w := workload{Kind: "statefulset", Name: item.Name, Meta: item.ObjectMeta, Selector: item.Spec.Selector, Template: item.Spec.Template}
+		w.Desired, w.Ready, w.Updated = 1, item.Status.ReadyReplicas, item.Status.UpdatedReplicas
+		if item.Spec.Replicas != nil {
+			w.Desired = *item.Spec.Replicas
+		}
+		msg, ok := statefulSetRolloutStatus(item)
+		w.Status, w.RollingOut = msg, !ok
+		workloads = append(workloads, w)
+	}
+
+	daemonSets, err := k.Client.AppsV1().DaemonSets(k.Namespace).List(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range daemonSets.Items {
+		w := workload{Kind: "daemonset", Name: item.Name, Meta: item.ObjectMeta, Selector: item.Spec.Selector, Template: item.Spec.Template}
+		w.Desired, w.Ready, w.Updated = item.Status.DesiredNumberScheduled, item.Status.NumberReady, item.Status.UpdatedNumberScheduled
+		msg, ok := daemonSetRolloutStatus(item)
+		w.Status, w.RollingOut = msg, !ok
+		workloads = append(workloads, w)
+	}
+
+	cronJobs, err := k.Client.BatchV1beta1().CronJobs(k.Namespace).List(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range cronJobs.Items {
+		w := workload{Kind: "cronjob", Name: item.Name, Meta: item.ObjectMeta, Template: item.Spec.JobTemplate.Spec.Template}
+		w.Desired = int32(len(item.Status.Active))
+		w.Ready, w.Updated = w.Desired, w.Desired
+		w.Status = "idle"
+		if w.Desired > 0 {
+			w.Status = fmt.Sprintf("%d job(s) running", w.Desired)
+		}
+		if item.Spec.Suspend != nil && *item.Spec.Suspend {
+			w.Status = "suspended"
+		}
+		workloads = append(workloads, w)
+	}
+
+	jobs, err := k.Client.BatchV1().Jobs(k.Namespace).List(context.Background(), opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range jobs.Items {
+		w := workload{Kind: "job", Name: item.Name, Meta: item.ObjectMeta, Selector: item.Spec.Selector, Template: item.Spec.Template}
+		w.Desired, w.Ready, w.Updated = item.Status.Active, item.Status.Active, item.Status.Active
+		switch {
+		case item.Status.Failed > 0 && item.Status.Active == 0 && item.Status.CompletionTime == nil:
+			w.Status = red(fmt.Sprintf("%d pod(s) failed", item.Status.Failed))
+		case item.Status.CompletionTime != nil:
+			w.Status = green("completed")
+		default:
+			w.Status = "running"
+		}
+		if item.Status.StartTime != nil {
+			w.Deployed = item.Status.StartTime.Time
+		}
+		workloads = append(workloads, w)
+	}
+
+	return workloads, nil
+}
+
+// groupWorkloads returns the workloads of every kind belonging to an app/env group
+func (k *KubeAPI) groupWorkloads(app, env string) ([]workload, error) {
+	return k.listWorkloads(groupSelector(app, env))
+}
+
+// groupDaemonSets returns all DaemonSets belonging to an app/env group
+func (k *KubeAPI) groupDaemonSets(app, env string) ([]appsv1.DaemonSet, error) {
+	list, err := k.Client.AppsV1().DaemonSets(k.Namespace).List(context.Background(), metav1.ListOptions{LabelSelector: groupSelector(app, env)})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func (k *KubeAPI) updateDaemonSet(app, env, tag, repo, container string) error {
+	dsClient := k.Client.AppsV1().DaemonSets(k.Namespace)
+
+	toUpdate, err := k.groupDaemonSets(app, env)
+	if err != nil {
+		return err
+	}
+
+	for _, ds := range toUpdate {
+		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			item, err := dsClient.Get(context.Background(), ds.ObjectMeta.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if len(setImage("daemonset", item.Name, &item.Spec.Template, tag, repo, container)) == 0 {
+				return nil
+			}
+			_, err = dsClient.Update(context.Background(), item, metav1.UpdateOptions{})
+			return err
+		})
+		if retryErr != nil {
+			return fmt.Errorf("deploy failed: %v", retryErr)
+		}
+	}
+
+	return nil
+}
+
+// updateCronJob updates the job template of the group's CronJobs. Jobs
+// already started keep running the image they were created with
+func (k *KubeAPI) updateCronJob(app, env, tag, repo, container string) error {
+	cjClient := k.Client.BatchV1beta1().CronJobs(k.Namespace)
+
+	toUpdate, err := cjClient.List(context.Background(), metav1.ListOptions{LabelSelector: groupSelector(app, env)})
+	if err != nil {
+		return err
+	}
+
+	for _, cj := range toUpdate.Items {
+		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			item, err := cjClient.Get(context.Background(), cj.ObjectMeta.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if len(setImage("cronjob", item.Name, &item.Spec.JobTemplate.Spec.Template, tag, repo, container)) == 0 {
+				return nil
+			}
+			_, err = cjClient.Update(context.Background(), item, metav1.UpdateOptions{})
+			return err
+		})
+		if retryErr != nil {
+			return fmt.Errorf("deploy failed: %v", retryErr)
+		}
+	}
+
+	return nil
+}
+
+// KindSummary counts the workloads of each kind changed by a deploy
+// e.g., 2 deployments, 1 cronjob
+func KindSummary(changes []ContainerChange) string {
+	names := map[string]map[string]bool{}
+	for _, c := range changes {
+		if names[c.Kind] == nil {
+			names[c.Kind] = map[string]bool{}
+		}
+		names[c.Kind][c.Name] = true
+	}
+
+	var summary []string
+	for _, kind := range kinds {
+		if n := len(names[kind]); n == 1 {
+			summary = append(summary, fmt.Sprintf("1 %s", kind))
+		} else if n > 1 {
+			summary = append(summary, fmt.Sprintf("%d %ss", n, kind))
+		}
+	}
+	return strings.Join(summary, ", ")
+}
@@ -117,6 +117,15 @@ func Delete(url string, keys []string, s *Secrets) (*Secrets, error) {
 	return s, nil
 }
 
+// NotFoundError is returned by Read when a secrets prefix does not exist
+type NotFoundError struct {
+	URL string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("Read access to Vault secrets %s denied. Either the key does not exist or your token does not have permission to access it", e.URL)
+}
+
 func readSecrets(url string) (*Secrets, error) {
 	client := &http.Client{}
 	req, _ := http.NewRequest("GET", url, strings.NewReader(""))
@@ -127,7 +136,7 @@ func readSecrets(url string) (*Secrets, error) {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode == http.StatusNotFound {
-		return nil, fmt.Errorf("Read access to Vault secrets %s denied. Either the key does not exist or your token does not have permission to access it", url)
+		return nil, &NotFoundError{URL: url}
 	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to fetch secrets: %s (%s)", url, resp.Status)
@@ -49,6 +49,18 @@ func TestRead(t *testing.T) {
 	if err == nil {
 		t.Error("expected error but got nil")
 	}
+	if _, notFound := err.(*NotFoundError); notFound {
+		t.Error("expected server error not to be a not found error")
+	}
+
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+	_, err = Read(ts.URL)
+	if _, notFound := err.(*NotFoundError); !notFound {
+		t.Errorf("expected not found error but got %v", err)
+	}
 }
 
 func TestWrite(t *testing.T) {